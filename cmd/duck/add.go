@@ -8,34 +8,46 @@ import (
 )
 
 func init() {
+	var in wizardInput
 	addCmd := &cobra.Command{
 		Use:   "add",
 		Short: "Add a new target to existing duck.yaml",
+		Long:  "Add a target to duck.yaml. Fields given as flags are not prompted for; with --yes nothing is prompted and a missing --name, --repo or --path is an error.",
+		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := loadConfig()
+			path, err := findConfigFile()
 			if err != nil {
 				return err
 			}
-			nt, name, err := runTargetWizard(false)
+			cfg, err := config.Load(path)
+			if err != nil {
+				return err
+			}
+			in.cfg = cfg
+			nt, name, err := runTargetWizard(false, &in, nil)
 			if err != nil {
 				return err
 			}
 			if name == "default" {
 				return fmt.Errorf("cannot add target with reserved name 'default'")
 			}
-			if cfg.Targets == nil {
-				cfg.Targets = map[string]config.Target{}
-			}
 			if _, exists := cfg.Targets[name]; exists {
 				return fmt.Errorf("target %s already exists", name)
 			}
-			cfg.Targets[name] = nt
-			if err := cfg.Save("duck.yaml"); err != nil {
+			doc, err := config.LoadDocument(path)
+			if err != nil {
+				return err
+			}
+			if err := doc.AddTarget(name, nt); err != nil {
+				return err
+			}
+			if err := doc.Save(path); err != nil {
 				return err
 			}
 			fmt.Println("Added target", name)
 			return nil
 		},
 	}
+	addWizardFlags(addCmd, &in)
 	rootCmd.AddCommand(addCmd)
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var checkStrict bool
+	checkCmd := &cobra.Command{
+		Use:   "check [target]",
+		Short: "Find variables templates use but targets do not define, and unused ones",
+		Long:  "Parse each target's template with its delimiters and report the variables it references ({{ .NAME }}) that the target does not define, with their line numbers, and the target's own variables the template never uses. Nothing is rendered and no !cmd variable runs. Missing variables make the command fail, even with allowMissing; use --strict to fail on unused variables too.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+				if target == cfg.Default.Name {
+					target = "default"
+				}
+			}
+			res, err := run.Check(cfg, target, os.Stdout)
+			if err != nil {
+				return err
+			}
+			switch {
+			case res.Missing > 0:
+				return fmt.Errorf("%d variable(s) used but not defined", res.Missing)
+			case checkStrict && res.Unused > 0:
+				return fmt.Errorf("%d variable(s) defined but not used", res.Unused)
+			}
+			return nil
+		},
+	}
+	checkCmd.Flags().BoolVar(&checkStrict, "strict", false, "Also fail when a target defines variables its template does not use")
+	rootCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var diffExitCode bool
+	diffCmd := &cobra.Command{
+		Use:   "diff [target]",
+		Short: "Show what a sync would change in rendered files",
+		Long:  "Render targets without touching the cache and print a unified diff against the file currently at each target's rendered path (the object behind its symlink, or a committed file). Provide an optional target to diff only that target. Use --exit-code in CI to fail when the rendered output drifts.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+				if target == cfg.Default.Name {
+					target = "default"
+				}
+			}
+			changed, err := run.Diff(cfg, target, os.Stdout)
+			if err != nil {
+				return err
+			}
+			if changed && diffExitCode {
+				return fmt.Errorf("rendered output differs from current files")
+			}
+			return nil
+		},
+	}
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Exit with status 1 when rendered output differs")
+	rootCmd.AddCommand(diffCmd)
+}
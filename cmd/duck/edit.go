@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var in wizardInput
+	editCmd := &cobra.Command{
+		Use:   "edit <target>",
+		Short: "Edit a target in duck.yaml",
+		Long:  "Re-run the add wizard for an existing target with its current values as defaults (Enter keeps a value, - clears it). Fields given as flags are not prompted for; with --yes every other field keeps its current value. The rest of duck.yaml, comments included, is left untouched.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := findConfigFile()
+			if err != nil {
+				return err
+			}
+			cfg, err := config.Load(path)
+			if err != nil {
+				return err
+			}
+			name := args[0]
+			cur, ok := cfg.Targets[name]
+			isDefault := name == "default" || name == cfg.Default.Name
+			if isDefault {
+				name, cur = "default", cfg.Default
+			} else if !ok {
+				return fmt.Errorf("unknown target %q", name)
+			}
+			in.cfg = cfg
+			t, _, err := runTargetWizard(isDefault, &in, &cur)
+			if err != nil {
+				return err
+			}
+			doc, err := config.LoadDocument(path)
+			if err != nil {
+				return err
+			}
+			if err := doc.UpdateTarget(name, t); err != nil {
+				return err
+			}
+			if err := doc.Save(path); err != nil {
+				return err
+			}
+			fmt.Println("Updated target", args[0])
+			return nil
+		},
+	}
+	addWizardFlags(editCmd, &in)
+	rootCmd.AddCommand(editCmd)
+}
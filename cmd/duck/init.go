@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"strings"
@@ -11,43 +10,47 @@ import (
 )
 
 func init() {
+	var in wizardInput
 	initCmd := &cobra.Command{
 		Use:   "init",
 		Short: "Interactive wizard to create a duck.yaml",
+		Long:  "Create duck.yaml with a default target. Fields given as flags are not prompted for; with --yes nothing is prompted, no further targets are offered, and a missing --repo or --path is an error.",
+		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if _, err := os.Stat("duck.yaml"); err == nil {
 				return fmt.Errorf("duck.yaml already exists")
 			}
-			return runInitWizard()
+			return runInitWizard(&in)
 		},
 	}
+	addWizardFlags(initCmd, &in)
 	rootCmd.AddCommand(initCmd)
 }
 
-func runInitWizard() error {
-	fmt.Println("Duckfile init wizard – press Enter to accept defaults or leave optional fields empty.")
-	first, _, err := runTargetWizard(true)
+func runInitWizard(in *wizardInput) error {
+	if !in.yes {
+		fmt.Println("Duckfile init wizard – press Enter to accept defaults or leave optional fields empty.")
+	}
+	first, _, err := runTargetWizard(true, in, nil)
 	if err != nil {
 		return err
 	}
-	cfg := &config.DuckConf{Version: 1, Default: first, Targets: map[string]config.Target{}}
-	if err := cfg.Save("duck.yaml"); err != nil {
+	doc := config.NewDocument()
+	if err := doc.SetDefault(first); err != nil {
+		return err
+	}
+	if err := doc.Save("duck.yaml"); err != nil {
 		return err
 	}
 	fmt.Println("Created duck.yaml with default target.")
-	reader := bufio.NewReader(os.Stdin)
-	for {
+	for !in.yes {
 		fmt.Print("Add another target? (y/N): ")
-		resp, _ := reader.ReadString('\n')
+		resp, _ := stdin.ReadString('\n')
 		resp = strings.TrimSpace(strings.ToLower(resp))
 		if resp != "y" && resp != "yes" {
 			break
 		}
-		cfg2, err := config.Load("duck.yaml")
-		if err != nil {
-			return err
-		}
-		nt, name, err := runTargetWizard(false)
+		nt, name, err := runTargetWizard(false, nil, nil)
 		if err != nil {
 			return err
 		}
@@ -55,15 +58,11 @@ func runInitWizard() error {
 			fmt.Println("Skipping – name 'default' is reserved.")
 			continue
 		}
-		if cfg2.Targets == nil {
-			cfg2.Targets = map[string]config.Target{}
-		}
-		if _, exists := cfg2.Targets[name]; exists {
-			fmt.Println("Target already exists; skipping.")
+		if err := doc.AddTarget(name, nt); err != nil {
+			fmt.Printf("%v; skipping.\n", err)
 			continue
 		}
-		cfg2.Targets[name] = nt
-		if err := cfg2.Save("duck.yaml"); err != nil {
+		if err := doc.Save("duck.yaml"); err != nil {
 			return err
 		}
 		fmt.Println("Added target", name)
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/CyberDuck79/duckfile/internal/source"
 	"github.com/spf13/cobra"
 )
 
@@ -14,16 +16,21 @@ func init() {
 		listShowRemote bool
 		listShowVars   bool
 		listShowExec   bool
+		listSet        overrideFlags
 	)
 	listCmd := &cobra.Command{
 		Use:   "list",
 		Short: "List targets defined in duck.yaml",
-		Long:  "List targets (default + named) from the configuration. Shows name and description by default. Use flags to include remote template, variables, and execution info.",
+		Long:  "List targets (default + named) from the configuration. Shows name and description by default. Use flags to include remote template, variables, and execution info. With -v, the variables declared by the template's manifest are listed too, which fetches the template. Variables given with --set, --set-string or --set-file are listed as overrides.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
+			set, err := listSet.parse()
+			if err != nil {
+				return err
+			}
 			fmt.Printf("%-12s %-12s %-s\n", "TARGET", "BINARY", "DESCRIPTION")
 			printTarget := func(key string, t config.Target) {
 				bin := t.Binary
@@ -32,39 +39,57 @@ func init() {
 				}
 				fmt.Printf("%-12s %-12s %-s\n", key, bin, t.Description)
 				if listShowRemote {
-					fmt.Printf("    repo: %s\n", t.Template.Repo)
-					ref := t.Template.Ref
-					if ref == "" {
-						ref = "HEAD"
+					if dir, ok := t.Template.LocalDir(); ok {
+						fmt.Printf("    local: %s\n", dir)
+					} else {
+						kind := source.Kind(t.Template)
+						fmt.Printf("    repo: %s\n", t.Template.Repo)
+						if kind != source.KindGit {
+							fmt.Printf("    type: %s\n", kind)
+						}
+						ref := t.Template.Ref
+						if ref == "" && kind == source.KindGit {
+							ref = "HEAD"
+						} else if ref == "" && kind == source.KindOCI {
+							ref = "latest"
+						}
+						if v := run.ResolvedVersion(key, t); v != "" {
+							ref += " (" + v + ")"
+						}
+						if ref != "" {
+							fmt.Printf("    ref: %s\n", ref)
+						}
 					}
-					fmt.Printf("    ref: %s\n", ref)
 					fmt.Printf("    path: %s\n", t.Template.Path)
 				}
-				if listShowVars && len(t.Variables) > 0 {
-					keys := make([]string, 0, len(t.Variables))
-					for k := range t.Variables {
+				if files := append(append([]string{}, cfg.VarsFiles...), t.VarsFiles...); listShowVars && len(files) > 0 {
+					fmt.Printf("    varsFiles: %s\n", strings.Join(files, ", "))
+				}
+				if listShowVars && len(t.Variables)+len(set) > 0 {
+					vars := map[string]config.VarValue{}
+					for k, v := range t.Variables {
+						vars[k] = v
+					}
+					for k, v := range set {
+						vars[k] = v
+					}
+					keys := make([]string, 0, len(vars))
+					for k := range vars {
 						keys = append(keys, k)
 					}
 					sort.Strings(keys)
 					fmt.Printf("    variables (%d):\n", len(keys))
 					for _, k := range keys {
-						vv := t.Variables[k]
-						var origin string
-						switch vv.Kind {
-						case config.VarLiteral:
-							origin = "literal"
-						case config.VarEnv:
-							origin = "env"
-						case config.VarCmd:
-							origin = "cmd"
-						case config.VarFile:
-							origin = "file"
-						default:
-							origin = "literal"
+						if _, ok := set[k]; ok {
+							fmt.Printf("      - %s (%s, override)\n", k, vars[k].Kind)
+						} else {
+							fmt.Printf("      - %s (%s)\n", k, vars[k].Kind)
 						}
-						fmt.Printf("      - %s (%s)\n", k, origin)
 					}
 				}
+				if listShowVars {
+					printManifest(cfg, key, t)
+				}
 				if listShowExec && t.Binary != "" {
 					fmt.Printf("    exec: %s %s <rendered> %s\n", t.Binary, t.FileFlag, strings.Join(t.Args, " "))
 				}
@@ -84,7 +109,38 @@ func init() {
 		},
 	}
 	listCmd.Flags().BoolVarP(&listShowRemote, "remote", "r", false, "Show remote template configuration (repo/ref/path/delims)")
-	listCmd.Flags().BoolVarP(&listShowVars, "vars", "v", false, "Show variable names and their kinds")
+	listCmd.Flags().BoolVarP(&listShowVars, "vars", "v", false, "Show variable names, their kinds and the template manifest")
 	listCmd.Flags().BoolVarP(&listShowExec, "exec", "e", false, "Show execution line (binary + file flag + args)")
+	listSet.register(listCmd)
 	rootCmd.AddCommand(listCmd)
 }
+
+// printManifest prints the variables declared by a target's template manifest.
+func printManifest(cfg *config.DuckConf, name string, t config.Target) {
+	m, err := run.TemplateManifest(cfg, name, t)
+	switch {
+	case err != nil:
+		fmt.Printf("    manifest: error: %s\n", firstLine(err.Error()))
+		return
+	case m == nil:
+		return
+	}
+	if m.Description != "" {
+		fmt.Printf("    manifest %s: %s\n", m.Path, m.Description)
+	} else {
+		fmt.Printf("    manifest %s:\n", m.Path)
+	}
+	keys := make([]string, 0, len(m.Variables))
+	for k := range m.Variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		spec := m.Variables[k].Spec()
+		if spec.Description != "" {
+			fmt.Printf("      - %s %s: %s\n", k, declSummary(spec), spec.Description)
+		} else {
+			fmt.Printf("      - %s %s\n", k, declSummary(spec))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/CyberDuck79/duckfile/internal/lock"
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var lockUpdate bool
+	lockCmd := &cobra.Command{
+		Use:   "lock [target]",
+		Short: "Pin every target's template to an exact commit in duck.lock",
+		Long:  "Resolve each target's template repo/ref to a commit SHA and template SHA-256 and write them to duck.lock. Existing pins are kept; use --update to re-resolve all targets, or --update <target> for one target.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+			if err := run.Lock(cfg, target, lockUpdate); err != nil {
+				return err
+			}
+			fmt.Println("Wrote", lock.FileName)
+			return nil
+		},
+	}
+	lockCmd.Flags().BoolVarP(&lockUpdate, "update", "u", false, "Re-resolve pinned refs (all targets, or only the given target)")
+	rootCmd.AddCommand(lockCmd)
+}
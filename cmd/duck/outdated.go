@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	outdatedCmd := &cobra.Command{
+		Use:   "outdated [target]",
+		Short: "Show template refs that have newer tags",
+		Long:  "Resolve each target's template.ref against its Git remote and print the commit it points to together with the newest semver tag of the repository. Targets pinned to an older tag, or following a version constraint that excludes the newest tag, are marked with the ref 'duck update' would write.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+				if target == cfg.Default.Name {
+					target = "default"
+				}
+			}
+			statuses, err := run.Outdated(cfg, target)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%-12s %-20s %-12s %-12s %-s\n", "TARGET", "REF", "COMMIT", "LATEST", "UPDATE")
+			failed := 0
+			for _, st := range statuses {
+				ref := st.Ref
+				if ref == "" && st.Note == "" {
+					ref = "HEAD"
+				}
+				if st.Tag != "" {
+					ref += " (" + st.Tag + ")"
+				}
+				commit, latest, update := shortSHA(st.Commit), orDash(st.Latest), orDash(st.Update)
+				switch {
+				case st.Err != nil:
+					failed++
+					commit, latest, update = "-", "-", "error: "+firstLine(st.Err.Error())
+				case st.Note != "":
+					commit, latest, update = "-", "-", st.Note
+				}
+				fmt.Printf("%-12s %-20s %-12s %-12s %-s\n", st.Target, orDash(ref), commit, latest, update)
+			}
+			if failed > 0 {
+				for _, st := range statuses {
+					if st.Err != nil {
+						fmt.Fprintf(os.Stderr, "%s: %v\n", st.Target, st.Err)
+					}
+				}
+				return fmt.Errorf("%d of %d targets could not be checked", failed, len(statuses))
+			}
+			return nil
+		},
+	}
+	rootCmd.AddCommand(outdatedCmd)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// overrideFlags collects --set, --set-string and --set-file variable overrides.
+type overrideFlags struct {
+	set, setString, setFile []string
+}
+
+func (o *overrideFlags) register(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringArrayVar(&o.set, "set", nil, "Override a variable, typed like a YAML scalar (KEY=VALUE, repeatable)")
+	f.StringArrayVar(&o.setString, "set-string", nil, "Override a variable with a string (KEY=VALUE, repeatable)")
+	f.StringArrayVar(&o.setFile, "set-file", nil, "Override a variable with a file's content (KEY=PATH, repeatable)")
+}
+
+// parseArg consumes an override flag of the manually parsed root command at
+// args[i], in either "--set KEY=VALUE" or "--set=KEY=VALUE" form. It returns how
+// many arguments were used, 0 when args[i] is not an override flag.
+func (o *overrideFlags) parseArg(args []string, i int) (int, error) {
+	name, value, inline := strings.Cut(args[i], "=")
+	var dst *[]string
+	switch name {
+	case "--set":
+		dst = &o.set
+	case "--set-string":
+		dst = &o.setString
+	case "--set-file":
+		dst = &o.setFile
+	default:
+		return 0, nil
+	}
+	if inline {
+		*dst = append(*dst, value)
+		return 1, nil
+	}
+	if i+1 >= len(args) {
+		return 0, fmt.Errorf("flag needs an argument: %s", name)
+	}
+	*dst = append(*dst, args[i+1])
+	return 2, nil
+}
+
+// parse returns the overrides by variable name. --set values are typed like plain
+// YAML scalars (8080 is an int, true a bool), --set-string values are always
+// strings and --set-file values read the file like !file. --set-file takes
+// precedence over --set-string, which takes precedence over --set; within one
+// flag the last value wins.
+func (o *overrideFlags) parse() (map[string]config.VarValue, error) {
+	out := map[string]config.VarValue{}
+	for _, group := range []struct {
+		pairs []string
+		mk    func(string) config.VarValue
+	}{
+		{o.set, config.ParseLiteralVar},
+		{o.setString, func(v string) config.VarValue { return config.NewLiteralVar(v) }},
+		{o.setFile, config.NewFileVar},
+	} {
+		for _, p := range group.pairs {
+			k, v, ok := strings.Cut(p, "=")
+			if !ok || strings.TrimSpace(k) == "" {
+				return nil, fmt.Errorf("invalid override %q: expected KEY=VALUE", p)
+			}
+			out[strings.TrimSpace(k)] = group.mk(v)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	removeCmd := &cobra.Command{
+		Use:     "remove <target>",
+		Aliases: []string{"rm"},
+		Short:   "Remove a target from duck.yaml",
+		Long:    "Clean a target's cached files and symlink, delete it from duck.yaml (keeping comments and formatting of the rest of the file) and drop its duck.lock entry. The default target cannot be removed.",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := findConfigFile()
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			target := args[0]
+			if target == cfg.Default.Name {
+				target = "default"
+			}
+			if err := run.Remove(cfg, path, target); err != nil {
+				return err
+			}
+			fmt.Println("Removed target", args[0])
+			return nil
+		},
+	}
+	rootCmd.AddCommand(removeCmd)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var (
+		renderSet          overrideFlags
+		renderOutput       string
+		renderTemplateFile string
+	)
+	renderCmd := &cobra.Command{
+		Use:   "render [target]",
+		Short: "Render a target's template to stdout without touching the cache",
+		Long:  "Render a target's template with its variables and print the result (or write it with -o). No cache object or symlink is written. Use --set KEY=VALUE, --set-string KEY=VALUE or --set-file KEY=PATH to override variables and --template-file to render a local file with the target's variables while iterating on a template.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+				if target == cfg.Default.Name {
+					target = "default"
+				}
+			}
+			set, err := renderSet.parse()
+			if err != nil {
+				return err
+			}
+			out, err := run.Render(cfg, target, run.RenderOptions{Set: set, TemplateFile: renderTemplateFile})
+			if err != nil {
+				return err
+			}
+			if renderOutput == "" || renderOutput == "-" {
+				_, err = os.Stdout.Write(out)
+				return err
+			}
+			return os.WriteFile(renderOutput, out, 0o644)
+		},
+	}
+	renderSet.register(renderCmd)
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "Write the result to this file instead of stdout")
+	renderCmd.Flags().StringVar(&renderTemplateFile, "template-file", "", "Render this local template file instead of the target's template")
+	rootCmd.AddCommand(renderCmd)
+}
@@ -13,7 +13,7 @@ import (
 var Version = "dev"
 
 var rootCmd = &cobra.Command{
-	Use:                "duck [target] -- [target_args...]",
+	Use:                "duck [target] [--set KEY=VALUE]... -- [target_args...]",
 	Short:              "Duckfiles – remote-templating wrapper",
 	SilenceUsage:       true,
 	SilenceErrors:      true,
@@ -25,6 +25,7 @@ var rootCmd = &cobra.Command{
 			showVersion bool
 			target      string
 			binArgs     []string
+			overrides   overrideFlags
 		)
 
 		// Find "--" separator
@@ -44,6 +45,14 @@ var rootCmd = &cobra.Command{
 
 		// Parse duckflags
 		for i := 0; i < len(duckArgs); i++ {
+			n, err := overrides.parseArg(duckArgs, i)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				i += n - 1
+				continue
+			}
 			switch duckArgs[i] {
 			case "-v", "--version":
 				showVersion = true
@@ -61,6 +70,10 @@ var rootCmd = &cobra.Command{
 			fmt.Println("duck version", Version)
 			return nil
 		}
+		set, err := overrides.parse()
+		if err != nil {
+			return err
+		}
 
 		// 1. detect config file
 		configFiles := []string{"duck.yaml", "duck.yml", ".duck.yaml", ".duck.yml"}
@@ -94,7 +107,7 @@ var rootCmd = &cobra.Command{
 		}
 
 		// 4. execute
-		return run.Exec(cfg, target, binArgs)
+		return run.Exec(cfg, target, binArgs, set)
 	},
 }
 
@@ -109,18 +122,21 @@ func main() {
 }
 
 func loadConfig() (*config.DuckConf, error) {
-	// detect config file
+	cfgFile, err := findConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	// load config
+	return config.Load(cfgFile)
+}
+
+// findConfigFile returns the first existing config file in the current directory.
+func findConfigFile() (string, error) {
 	configFiles := []string{"duck.yaml", "duck.yml", ".duck.yaml", ".duck.yml"}
-	var cfgFile string
 	for _, f := range configFiles {
 		if _, err := os.Stat(f); err == nil {
-			cfgFile = f
-			break
+			return f, nil
 		}
 	}
-	if cfgFile == "" {
-		return nil, fmt.Errorf("no config file found (tried: %v)", configFiles)
-	}
-	// load config
-	return config.Load(cfgFile)
+	return "", fmt.Errorf("no config file found (tried: %v)", configFiles)
 }
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/CyberDuck79/duckfile/internal/run"
 	"github.com/spf13/cobra"
 )
 
 func init() {
-	var syncForce bool
+	var (
+		syncForce  bool
+		syncFrozen bool
+		syncJobs   int
+		syncSet    overrideFlags
+	)
 	syncCmd := &cobra.Command{
 		Use:   "sync [target]",
 		Short: "Sync templates into cache without executing",
-		Long:  "Sync templates into the deterministic cache (.duck/objects) and update symlinks. Provide an optional target to sync only that target. Use -f/--force to re-render ignoring existing cache.",
+		Long:  "Sync templates into the deterministic cache (.duck/objects) and update symlinks. Provide an optional target to sync only that target. Use -f/--force to re-render ignoring existing cache. Use --frozen in CI to fail when duck.lock is missing or stale. Use --set, --set-string and --set-file to override variables of the synced targets. Use -j/--jobs to sync several targets concurrently; a failing target does not stop the others and a summary is printed at the end.",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := loadConfig()
@@ -21,9 +30,56 @@ func init() {
 			if len(args) > 0 {
 				target = args[0]
 			}
-			return run.Sync(cfg, target, syncForce)
+			set, err := syncSet.parse()
+			if err != nil {
+				return err
+			}
+			results, err := run.Sync(cfg, target, run.SyncOptions{Force: syncForce, Frozen: syncFrozen, Jobs: syncJobs, Set: set})
+			printSyncSummary(results)
+			return err
 		},
 	}
 	syncCmd.Flags().BoolVarP(&syncForce, "force", "f", false, "Force re-render even if cache exists")
+	syncCmd.Flags().BoolVar(&syncFrozen, "frozen", false, "Fail if duck.lock is missing or stale relative to duck.yaml")
+	syncCmd.Flags().IntVarP(&syncJobs, "jobs", "j", 1, "Number of targets to sync concurrently")
+	syncSet.register(syncCmd)
 	rootCmd.AddCommand(syncCmd)
 }
+
+// printSyncSummary prints one line per target, in target name order.
+func printSyncSummary(results []run.SyncResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Printf("%-12s %-9s %-12s %-s\n", "TARGET", "STATUS", "COMMIT", "DETAIL")
+	for _, r := range results {
+		commit, detail := shortSHA(r.Commit), r.Key
+		if commit == "" {
+			commit = "local"
+		}
+		if r.Err != nil {
+			commit, detail = "-", firstLine(r.Err.Error())
+		}
+		fmt.Printf("%-12s %-9s %-12s %-s\n", r.Target, r.Status, commit, detail)
+	}
+	// A lone target's error is reported by the caller; otherwise show every failure in full
+	if len(results) > 1 {
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Target, r.Err)
+			}
+		}
+	}
+}
+
+// firstLine keeps table rows on one line; multi-line errors are printed in full separately.
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var updateTo string
+	updateCmd := &cobra.Command{
+		Use:   "update [target] [--to TAG]",
+		Short: "Move template refs to newer tags and re-sync",
+		Long:  "Rewrite template.ref in duck.yaml for targets that 'duck outdated' reports as behind (the newest tag, or a bumped ^/~ constraint), keeping comments and formatting, then re-lock (when duck.lock exists) and re-sync them. Use --to with a target to set an explicit tag, branch, commit or constraint.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := findConfigFile()
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+				if target == cfg.Default.Name {
+					target = "default"
+				}
+			}
+			results, err := run.Update(cfg, path, target, updateTo)
+			printSyncSummary(results)
+			return err
+		},
+	}
+	updateCmd.Flags().StringVar(&updateTo, "to", "", "Set the target's ref to this tag (or branch, commit, constraint) instead of the newest tag")
+	rootCmd.AddCommand(updateCmd)
+}
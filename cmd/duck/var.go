@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	varCmd := &cobra.Command{
+		Use:   "var",
+		Short: "List, set and unset target variables",
+		Long:  "Manage the variables of targets in duck.yaml. set and unset edit the file in place, keeping comments and formatting.",
+	}
+
+	var showValues, unmask bool
+	listCmd := &cobra.Command{
+		Use:   "list [target]",
+		Short: "List variables with their kind",
+		Long:  "List each variable's target, name, kind (literal/env/cmd/file) and definition. With --values, also resolve and print the value templates receive; values coming from env, cmd and file variables are masked unless --unmask is given.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			targets := map[string]config.Target{"default": cfg.Default}
+			for name, t := range cfg.Targets {
+				targets[name] = t
+			}
+			names := []string{"default"}
+			if len(args) > 0 {
+				name := args[0]
+				if name == cfg.Default.Name {
+					name = "default"
+				}
+				if _, ok := targets[name]; !ok {
+					return fmt.Errorf("unknown target %q", name)
+				}
+				names = []string{name}
+			} else {
+				keys := make([]string, 0, len(cfg.Targets))
+				for k := range cfg.Targets {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				names = append(names, keys...)
+			}
+
+			if showValues {
+				fmt.Printf("%-12s %-16s %-8s %-24s %-s\n", "TARGET", "NAME", "KIND", "DEFINITION", "VALUE")
+			} else {
+				fmt.Printf("%-12s %-16s %-8s %-s\n", "TARGET", "NAME", "KIND", "DEFINITION")
+			}
+			for _, name := range names {
+				vars := targets[name].Variables
+				keys := make([]string, 0, len(vars))
+				for k := range vars {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					v := vars[k]
+					def := v.Arg
+					switch {
+					case !v.IsSet():
+						def = "-"
+					case v.Kind == config.VarLiteral:
+						def = displayValue(v.Value)
+					case v.Kind == config.VarMap || v.Kind == config.VarList:
+						plain := v
+						plain.Spec = nil
+						def = plain.String()
+					}
+					if v.Spec != nil {
+						def += " " + declSummary(*v.Spec)
+					}
+					if !showValues {
+						fmt.Printf("%-12s %-16s %-8s %-s\n", name, k, v.Kind, def)
+						continue
+					}
+					var value string
+					switch val, err := run.ResolveVariable(k, v); {
+					case err != nil:
+						value = "error: " + firstLine(err.Error())
+					case !unmask:
+						value = displayValue(maskValue(v, val))
+					default:
+						value = displayValue(val)
+					}
+					fmt.Printf("%-12s %-16s %-8s %-24s %-s\n", name, k, v.Kind, def, value)
+				}
+			}
+			return nil
+		},
+	}
+	listCmd.Flags().BoolVar(&showValues, "values", false, "Resolve variables and show their values")
+	listCmd.Flags().BoolVar(&unmask, "unmask", false, "With --values, show env, cmd and file values in clear")
+
+	var asEnv, asCmd, asFile bool
+	setCmd := &cobra.Command{
+		Use:   "set <target> KEY [--env|--cmd|--file] VALUE",
+		Short: "Set a variable of a target",
+		Long:  "Set a target variable in duck.yaml. VALUE is stored as a literal string, or with --env, --cmd or --file as an !env NAME, !cmd COMMAND or !file PATH variable. An existing variable is overwritten in place, keeping its comments.",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := config.NewLiteralVar(args[2])
+			switch {
+			case asEnv:
+				v = config.NewEnvVar(args[2])
+			case asCmd:
+				v = config.NewCmdVar(args[2])
+			case asFile:
+				v = config.NewFileVar(args[2])
+			}
+			return editVariables(args[0], func(doc *config.Document, target string) error {
+				return doc.SetVariable(target, args[1], v)
+			})
+		},
+	}
+	setCmd.Flags().BoolVar(&asEnv, "env", false, "VALUE is the name of an environment variable")
+	setCmd.Flags().BoolVar(&asCmd, "cmd", false, "VALUE is a shell command whose output is used")
+	setCmd.Flags().BoolVar(&asFile, "file", false, "VALUE is a path to a file whose content is used")
+	setCmd.MarkFlagsMutuallyExclusive("env", "cmd", "file")
+
+	unsetCmd := &cobra.Command{
+		Use:   "unset <target> KEY",
+		Short: "Remove a variable from a target",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return editVariables(args[0], func(doc *config.Document, target string) error {
+				return doc.UnsetVariable(target, args[1])
+			})
+		},
+	}
+
+	varCmd.AddCommand(listCmd, setCmd, unsetCmd)
+	rootCmd.AddCommand(varCmd)
+}
+
+// editVariables applies edit to the target's entry in duck.yaml and saves the file.
+func editVariables(target string, edit func(doc *config.Document, target string) error) error {
+	path, err := findConfigFile()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	if target == cfg.Default.Name {
+		target = "default"
+	}
+	doc, err := config.LoadDocument(path)
+	if err != nil {
+		return err
+	}
+	if err := edit(doc, target); err != nil {
+		return err
+	}
+	return doc.Save(path)
+}
+
+// maskValue replaces the values that env, cmd and file variables of v contributed
+// to val, the value resolved from v.
+func maskValue(v config.VarValue, val any) any {
+	if !v.IsSet() && v.Spec != nil && v.Spec.Default != nil {
+		v = *v.Spec.Default
+	}
+	switch v.Kind {
+	case config.VarLiteral:
+		return val
+	case config.VarMap:
+		m, _ := val.(map[string]any)
+		out := make(map[string]any, len(v.Map))
+		for k, c := range v.Map {
+			out[k] = maskValue(c, m[k])
+		}
+		return out
+	case config.VarList:
+		l, _ := val.([]any)
+		out := make([]any, len(v.List))
+		for i, c := range v.List {
+			if i < len(l) {
+				out[i] = maskValue(c, l[i])
+			}
+		}
+		return out
+	default:
+		return "********"
+	}
+}
+
+// declSummary describes a `!var` declaration, e.g. "[int, required, default 8080]".
+func declSummary(s config.VarSpec) string {
+	var parts []string
+	if s.Type != "" {
+		parts = append(parts, s.Type)
+	}
+	if s.Required {
+		parts = append(parts, "required")
+	}
+	if s.Default != nil {
+		parts = append(parts, "default "+s.Default.String())
+	}
+	if len(s.Enum) > 0 {
+		parts = append(parts, fmt.Sprintf("one of %v", s.Enum))
+	}
+	if s.Pattern != "" {
+		parts = append(parts, "pattern "+s.Pattern)
+	}
+	if len(parts) == 0 {
+		return "[declared]"
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// displayValue prints a variable value on one line, quoting strings that span
+// several lines or have surrounding spaces and printing structures as JSON.
+func displayValue(v any) string {
+	switch v.(type) {
+	case map[string]any, []any:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	if q := strconv.Quote(s); s == "" || q[1:len(q)-1] != s || s[0] == ' ' || s[len(s)-1] == ' ' {
+		return q
+	}
+	return s
+}
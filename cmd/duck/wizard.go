@@ -3,134 +3,427 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/run"
+	"github.com/spf13/cobra"
 )
 
-// runTargetWizard collects target info interactively.
-func runTargetWizard(isDefault bool) (config.Target, string, error) {
-	reader := bufio.NewReader(os.Stdin)
+// stdin is shared by every prompt so that buffered input is not lost between readers.
+var stdin = bufio.NewReader(os.Stdin)
+
+// wizardInput holds target fields supplied as flags; the wizard only prompts for
+// the others, or fails on missing required ones with --yes.
+type wizardInput struct {
+	name, binary, fileFlag, renderedPath string
+	repo, ref, path, delims              string
+	allowMissing                         bool
+	vars, envVars, cmdVars, fileVars     []string
+	yes                                  bool
+	cmd                                  *cobra.Command
+	cfg                                  *config.DuckConf // settings used to fetch the template manifest; nil in duck init
+}
+
+// addWizardFlags registers the target flags shared by `duck add` and `duck init`.
+func addWizardFlags(cmd *cobra.Command, in *wizardInput) {
+	in.cmd = cmd
+	f := cmd.Flags()
+	f.StringVar(&in.name, "name", "", "Target key (add) or default target name (init, edit default)")
+	f.StringVar(&in.binary, "binary", "", "Binary to execute (empty for sync-only)")
+	f.StringVar(&in.fileFlag, "file-flag", "", "Flag passing the rendered file to the binary (e.g. -f)")
+	f.StringVar(&in.repo, "repo", "", "Template repository URL")
+	f.StringVar(&in.ref, "ref", "", "Template ref (branch, tag, commit or version constraint)")
+	f.StringVar(&in.path, "path", "", "Template path inside the repository")
+	f.StringVar(&in.renderedPath, "rendered-path", "", "Where the rendered file's symlink should appear")
+	f.BoolVar(&in.allowMissing, "allow-missing", false, "Render missing variables as empty strings")
+	f.StringArrayVar(&in.vars, "var", nil, "Literal variable KEY=VALUE (repeatable)")
+	f.StringArrayVar(&in.envVars, "env-var", nil, "Environment variable KEY=NAME (repeatable)")
+	f.StringArrayVar(&in.cmdVars, "cmd-var", nil, "Command variable KEY=COMMAND (repeatable)")
+	f.StringArrayVar(&in.fileVars, "file-var", nil, "File variable KEY=PATH (repeatable)")
+	f.StringVar(&in.delims, "delims", "", "Template delimiters as LEFT,RIGHT (e.g. '[[,]]')")
+	f.BoolVarP(&in.yes, "yes", "y", false, "Never prompt; fail when a required field is missing")
+}
+
+func (in *wizardInput) given(flag string) bool {
+	return in.cmd != nil && in.cmd.Flags().Changed(flag)
+}
+
+func (in *wizardInput) hasVars() bool {
+	return len(in.vars)+len(in.envVars)+len(in.cmdVars)+len(in.fileVars) > 0
+}
+
+// runTargetWizard collects target info from flags and interactive prompts. When cur
+// is set (duck edit) its values are the defaults: Enter keeps a value, "-" clears
+// it, and fields the wizard does not cover are carried over unchanged.
+func runTargetWizard(isDefault bool, in *wizardInput, cur *config.Target) (config.Target, string, error) {
+	if in == nil {
+		in = &wizardInput{}
+	}
+	editing := cur != nil
+	targ := config.Target{}
+	if editing {
+		targ = *cur
+		if !in.yes {
+			fmt.Println("Press Enter to keep the current value, or enter - to clear it.")
+		}
+	}
 	ask := func(prompt string) (string, error) {
 		fmt.Print(prompt)
-		txt, err := reader.ReadString('\n')
-		if err != nil {
+		txt, err := stdin.ReadString('\n')
+		if err != nil && (err != io.EOF || txt == "") {
 			return "", err
 		}
 		return strings.TrimSpace(txt), nil
 	}
+	// field returns the flag value when given, the current value (or def) with --yes or
+	// an empty answer, otherwise the answer to the prompt
+	field := func(flag, value, label, hint, def, current string) (string, error) {
+		if in.given(flag) {
+			return strings.TrimSpace(value), nil
+		}
+		if editing {
+			def, hint = current, current
+		}
+		if in.yes {
+			return def, nil
+		}
+		prompt := label + ": "
+		if hint != "" {
+			prompt = label + " [" + hint + "]: "
+		}
+		ans, err := ask(prompt)
+		switch {
+		case err != nil:
+			return "", err
+		case ans == "":
+			return def, nil
+		case ans == "-" && editing:
+			return "", nil
+		}
+		return ans, nil
+	}
+	required := func(flag, value, what string) error {
+		if value != "" {
+			return nil
+		}
+		if in.yes {
+			return fmt.Errorf("%s is required: pass --%s", what, flag)
+		}
+		return fmt.Errorf("%s is required", what)
+	}
+
 	var name string
 	var err error
-	if isDefault {
-		name, err = ask("Name (human readable) [build]: ")
-		if err != nil {
+	switch {
+	case isDefault:
+		if name, err = field("name", in.name, "Name (human readable)", "build", "build", targ.Name); err != nil {
 			return config.Target{}, "", err
 		}
-		if name == "" {
-			name = "build"
-		}
-	} else {
-		name, err = ask("Target key (CLI name): ")
-		if err != nil {
+		targ.Name = name
+	case editing:
+		name = targ.Name
+	default:
+		if name, err = field("name", in.name, "Target key (CLI name)", "", "", ""); err != nil {
 			return config.Target{}, "", err
 		}
-		if name == "" {
-			return config.Target{}, "", fmt.Errorf("target key cannot be empty")
+		if err := required("name", name, "target key"); err != nil {
+			return config.Target{}, "", err
 		}
+		targ.Name = name
 	}
-	binary, err := ask("Binary (leave empty for sync-only): ")
-	if err != nil {
+	if targ.Binary, err = field("binary", in.binary, "Binary (leave empty for sync-only)", "", "", targ.Binary); err != nil {
 		return config.Target{}, "", err
 	}
-	var fileFlag string
-	if strings.TrimSpace(binary) != "" {
-		fileFlag, err = ask("fileFlag (e.g. -f, --taskfile) [optional if binary expects path implicitly]: ")
+	if targ.Binary != "" {
+		targ.FileFlag, err = field("file-flag", in.fileFlag, "fileFlag (e.g. -f, --taskfile)", "optional if binary expects path implicitly", "", targ.FileFlag)
 		if err != nil {
 			return config.Target{}, "", err
 		}
+	} else {
+		targ.FileFlag = ""
 	}
-	renderedPath, err := ask("Rendered path (where symlink/file should appear) [auto .duck/<target>/<base>]: ")
+	targ.RenderedPath, err = field("rendered-path", in.renderedPath, "Rendered path (where symlink/file should appear)", "auto .duck/<target>/<base>", "", targ.RenderedPath)
 	if err != nil {
 		return config.Target{}, "", err
 	}
-	repo, err := ask("Template repo (git URL): ")
-	if err != nil {
+	tpl := &targ.Template
+	if editing && tpl.Local != "" && in.given("repo") {
+		// --repo switches a local template to a repository
+		tpl.Local = ""
+		if tpl.Type == "local" {
+			tpl.Type = ""
+		}
+	}
+	if editing && tpl.Local != "" {
+		if in.given("ref") {
+			return config.Target{}, "", fmt.Errorf("--ref does not apply to the local template %s: pass --repo to switch it to a repository", tpl.Local)
+		}
+	} else {
+		if tpl.Repo, err = field("repo", in.repo, "Template repo (git URL)", "", "", tpl.Repo); err != nil {
+			return config.Target{}, "", err
+		}
+		if err := required("repo", tpl.Repo, "repo"); err != nil {
+			return config.Target{}, "", err
+		}
+		if tpl.Ref, err = field("ref", in.ref, "Template ref (branch/tag/commit)", "HEAD", "", tpl.Ref); err != nil {
+			return config.Target{}, "", err
+		}
+	}
+	if tpl.Path, err = field("path", in.path, "Template path inside repo (e.g. Makefile.tpl)", "", "", tpl.Path); err != nil {
 		return config.Target{}, "", err
 	}
-	if repo == "" {
-		return config.Target{}, "", fmt.Errorf("repo is required")
+	if err := required("path", tpl.Path, "template path"); err != nil {
+		return config.Target{}, "", err
+	}
+	if !strings.HasSuffix(tpl.Path, ".tpl") && !in.yes && !editing {
+		fmt.Println("(note) It's common to suffix template files with .tpl for clarity.")
 	}
-	ref, err := ask("Template ref (branch/tag/commit) [HEAD]: ")
+	if in.given("allow-missing") {
+		tpl.AllowMissing = in.allowMissing
+	} else if !in.yes {
+		prompt := "Allow missing variables? (y/N): "
+		if tpl.AllowMissing {
+			prompt = "Allow missing variables? (Y/n): "
+		}
+		ans, err := ask(prompt)
+		if err != nil {
+			return config.Target{}, "", err
+		}
+		if ans != "" {
+			tpl.AllowMissing = strings.HasPrefix(strings.ToLower(ans), "y")
+		}
+	}
+	var curDelims string
+	if tpl.Delims != nil {
+		curDelims = tpl.Delims.Left + "," + tpl.Delims.Right
+	}
+	delimsAns, err := field("delims", in.delims, "Template delimiters as LEFT,RIGHT (e.g. [[,]])", "{{,}}", "", curDelims)
 	if err != nil {
 		return config.Target{}, "", err
 	}
-	path, err := ask("Template path inside repo (e.g. Makefile.tpl): ")
-	if err != nil {
+	if tpl.Delims, err = parseDelims(delimsAns); err != nil {
 		return config.Target{}, "", err
 	}
-	if path == "" {
-		return config.Target{}, "", fmt.Errorf("template path is required")
+
+	vars := map[string]config.VarValue{}
+	for k, v := range targ.Variables {
+		vars[k] = v
 	}
-	if !strings.HasSuffix(path, ".tpl") {
-		fmt.Println("(note) It's common to suffix template files with .tpl for clarity.")
+	if in.hasVars() || in.yes {
+		given, err := flagVariables(in)
+		if err != nil {
+			return config.Target{}, "", err
+		}
+		for k, v := range given {
+			v.Spec = vars[k].Spec
+			vars[k] = v
+		}
+	} else {
+		lockName := name
+		if isDefault {
+			lockName = "default"
+		}
+		if err := askManifestVariables(ask, in.cfg, lockName, targ, vars); err != nil {
+			return config.Target{}, "", err
+		}
+		if editing {
+			if err := askExistingVariables(ask, vars); err != nil {
+				return config.Target{}, "", err
+			}
+		}
+		if err := askVariables(ask, vars); err != nil {
+			return config.Target{}, "", err
+		}
 	}
-	allowMissingAns, err := ask("Allow missing variables? (y/N): ")
-	if err != nil {
+	targ.Variables = vars
+	if len(vars) == 0 {
+		targ.Variables = nil
+	}
+	if err := config.ValidateTarget(targ, name); err != nil {
 		return config.Target{}, "", err
 	}
-	allowMissing := strings.HasPrefix(strings.ToLower(allowMissingAns), "y")
+	return targ, name, nil
+}
+
+// parseDelims parses LEFT,RIGHT; empty input keeps the default delimiters.
+func parseDelims(s string) (*config.Delims, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	left, right, ok := strings.Cut(s, ",")
+	left, right = strings.TrimSpace(left), strings.TrimSpace(right)
+	if !ok || left == "" || right == "" {
+		return nil, fmt.Errorf("invalid delimiters %q: expected LEFT,RIGHT (e.g. '[[,]]')", s)
+	}
+	return &config.Delims{Left: left, Right: right}, nil
+}
+
+// flagVariables builds the variables given with --var, --env-var, --cmd-var and --file-var.
+func flagVariables(in *wizardInput) (map[string]config.VarValue, error) {
 	vars := map[string]config.VarValue{}
+	for _, group := range []struct {
+		pairs []string
+		mk    func(string) config.VarValue
+	}{
+		{in.vars, func(v string) config.VarValue { return config.NewLiteralVar(v) }},
+		{in.envVars, config.NewEnvVar},
+		{in.cmdVars, config.NewCmdVar},
+		{in.fileVars, config.NewFileVar},
+	} {
+		kv, err := parseKeyValues(group.pairs)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range kv {
+			if _, dup := vars[k]; dup {
+				return nil, fmt.Errorf("variable %s given more than once", k)
+			}
+			vars[k] = group.mk(v)
+		}
+	}
+	return vars, nil
+}
+
+// parseKeyValues parses repeated KEY=VALUE flags.
+func parseKeyValues(pairs []string) (map[string]string, error) {
+	out := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid %q: expected KEY=VALUE", p)
+		}
+		out[strings.TrimSpace(k)] = v
+	}
+	return out, nil
+}
+
+// askManifestVariables prompts for the variables the template's manifest declares
+// and vars does not set yet. Enter leaves a variable to its manifest default.
+func askManifestVariables(ask func(string) (string, error), cfg *config.DuckConf, name string, t config.Target, vars map[string]config.VarValue) error {
+	if cfg == nil {
+		cfg = &config.DuckConf{}
+	}
+	m, err := run.TemplateManifest(cfg, name, t)
+	if err != nil {
+		fmt.Println("(note) Could not read the template manifest:", firstLine(err.Error()))
+		return nil
+	}
+	if m == nil || len(m.Variables) == 0 {
+		return nil
+	}
+	fmt.Printf("Template manifest %s declares %d variable(s).\n", m.Path, len(m.Variables))
+	if m.Description != "" {
+		fmt.Println(m.Description)
+	}
+	keys := make([]string, 0, len(m.Variables))
+	for k := range m.Variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := vars[k]; ok {
+			continue
+		}
+		spec := m.Variables[k].Spec()
+		prompt := "  " + k
+		if spec.Description != "" {
+			prompt += " (" + spec.Description + ")"
+		}
+		ans, err := ask(prompt + " " + declSummary(spec) + ": ")
+		if err != nil {
+			return err
+		}
+		switch {
+		case ans == "" && spec.Required && spec.Default == nil:
+			fmt.Printf("  (note) %s is required; set it before syncing.\n", k)
+		case ans == "":
+		case spec.Type == "string":
+			vars[k] = config.NewLiteralVar(ans)
+		default:
+			vars[k] = config.ParseLiteralVar(ans)
+		}
+	}
+	return nil
+}
+
+// askExistingVariables lets the user keep, change or remove each current variable.
+func askExistingVariables(ask func(string) (string, error), vars map[string]config.VarValue) error {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ans, err := ask(fmt.Sprintf("Variable %s = %s: keep, change or remove? [K/c/r]: ", k, vars[k]))
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(ans) {
+		case "c", "change":
+			v, err := askVariable(ask)
+			if err != nil {
+				return err
+			}
+			v.Spec = vars[k].Spec
+			vars[k] = v
+		case "r", "remove":
+			delete(vars, k)
+		}
+	}
+	return nil
+}
+
+// askVariables prompts for new variables until the user declines.
+func askVariables(ask func(string) (string, error), vars map[string]config.VarValue) error {
 	for {
 		more, err := ask("Add variable? (y/N): ")
 		if err != nil {
-			return config.Target{}, "", err
+			return err
 		}
 		if strings.ToLower(strings.TrimSpace(more)) != "y" {
-			break
+			return nil
 		}
 		k, err := ask("  Key: ")
 		if err != nil {
-			return config.Target{}, "", err
+			return err
 		}
 		if k == "" {
 			fmt.Println("  Skipping empty key")
 			continue
 		}
-		kind, err := ask("  Type (literal/env/cmd/file) [literal]: ")
-		if err != nil {
-			return config.Target{}, "", err
-		}
-		kind = strings.ToLower(strings.TrimSpace(kind))
-		switch kind {
-		case "", "literal":
-			v, _ := ask("  Value: ")
-			vars[k] = config.NewLiteralVar(v)
-		case "env":
-			v, _ := ask("  Env var name: ")
-			vars[k] = config.NewEnvVar(v)
-		case "cmd":
-			v, _ := ask("  Shell command: ")
-			vars[k] = config.NewCmdVar(v)
-		case "file":
-			v, _ := ask("  File path: ")
-			vars[k] = config.NewFileVar(v)
-		default:
-			fmt.Println("  Unknown type; storing as literal string")
-			v, _ := ask("  Value: ")
-			vars[k] = config.NewLiteralVar(v)
+		if vars[k], err = askVariable(ask); err != nil {
+			return err
 		}
 	}
-	targ := config.Target{
-		Name:         name,
-		Binary:       binary,
-		FileFlag:     fileFlag,
-		Template:     config.Template{Repo: repo, Ref: ref, Path: path, AllowMissing: allowMissing},
-		Variables:    vars,
-		RenderedPath: renderedPath,
+}
+
+// askVariable prompts for a variable's type and value.
+func askVariable(ask func(string) (string, error)) (config.VarValue, error) {
+	kind, err := ask("  Type (literal/env/cmd/file) [literal]: ")
+	if err != nil {
+		return config.VarValue{}, err
 	}
-	if err := config.ValidateTarget(targ, name); err != nil {
-		return config.Target{}, "", err
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	switch kind {
+	case "", "literal":
+		v, _ := ask("  Value: ")
+		return config.NewLiteralVar(v), nil
+	case "env":
+		v, _ := ask("  Env var name: ")
+		return config.NewEnvVar(v), nil
+	case "cmd":
+		v, _ := ask("  Shell command: ")
+		return config.NewCmdVar(v), nil
+	case "file":
+		v, _ := ask("  File path: ")
+		return config.NewFileVar(v), nil
+	default:
+		fmt.Println("  Unknown type; storing as literal string")
+		v, _ := ask("  Value: ")
+		return config.NewLiteralVar(v), nil
 	}
-	return targ, name, nil
 }
@@ -0,0 +1,192 @@
+// Package archive extracts tar and zip streams into directories, refusing entries
+// that would escape the destination.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractTar extracts an uncompressed tar stream into dir. A symlink pointing
+// outside dir fails the extraction.
+func ExtractTar(r io.Reader, dir string) error {
+	return extractTar(r, dir, nil)
+}
+
+// ExtractTarSkipLinks extracts a trusted tar stream, such as git archive output,
+// into dir. A symlink pointing outside dir is not created but passed to skipped,
+// so that a repository holding such links can still be used.
+func ExtractTarSkipLinks(r io.Reader, dir string, skipped func(name, link string)) error {
+	return extractTar(r, dir, skipped)
+}
+
+func extractTar(r io.Reader, dir string, skipped func(name, link string)) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p, ok := entryPath(dir, hdr.Name)
+		if !ok {
+			continue
+		}
+		if err := checkParents(dir, p); err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(p, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(p, tr, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				return err
+			}
+			if !linkInside(dir, p, hdr.Linkname) {
+				if skipped != nil {
+					skipped(hdr.Name, hdr.Linkname)
+					continue
+				}
+				return fmt.Errorf("archive entry %s: symlink to %s leaves the destination", hdr.Name, hdr.Linkname)
+			}
+			if err := removeSymlink(p); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, p); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ExtractTarGz extracts a gzip-compressed tar stream into dir.
+func ExtractTarGz(r io.Reader, dir string) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return ExtractTar(zr, dir)
+}
+
+// ExtractZip extracts the zip file at path into dir.
+func ExtractZip(path, dir string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		p, ok := entryPath(dir, f.Name)
+		if !ok {
+			continue
+		}
+		if err := checkParents(dir, p); err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(p, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(p, rc, f.Mode())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryPath maps an archive entry name into dir, rejecting absolute and escaping names.
+func entryPath(dir, name string) (string, bool) {
+	name = filepath.Clean(filepath.FromSlash(name))
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.Join(dir, name), true
+}
+
+// linkInside reports whether a symlink at p with target link resolves inside dir,
+// judging the target lexically.
+func linkInside(dir, p, link string) bool {
+	if filepath.IsAbs(link) {
+		return false
+	}
+	rel, err := filepath.Rel(dir, filepath.Join(filepath.Dir(p), link))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkParents fails when a directory between dir and p is a symlink: an entry
+// must never be written through a link an earlier entry created.
+func checkParents(dir, p string) error {
+	rel, err := filepath.Rel(dir, filepath.Dir(p))
+	if err != nil || rel == "." {
+		return err
+	}
+	cur := dir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		fi, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("archive entry %s: path goes through symlink %s", p, cur)
+		}
+	}
+	return nil
+}
+
+// removeSymlink removes p if it is a symlink, so that it is replaced rather than
+// followed.
+func removeSymlink(p string) error {
+	if fi, err := os.Lstat(p); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(p)
+	}
+	return nil
+}
+
+func writeFile(p string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	if err := removeSymlink(p); err != nil {
+		return err
+	}
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0o644
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("extract %s: %w", p, err)
+	}
+	return f.Close()
+}
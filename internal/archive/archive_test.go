@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type entry struct {
+	name, body, link string
+	dir              bool
+}
+
+func tarball(t *testing.T, entries ...entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.dir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.link
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractTar(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		wantErr string
+		files   map[string]string // path under dst -> content
+	}{
+		{
+			name:    "plain",
+			entries: []entry{{name: "a/", dir: true}, {name: "a/b.txt", body: "b"}, {name: "c.txt", body: "c"}},
+			files:   map[string]string{"a/b.txt": "b", "c.txt": "c"},
+		},
+		{
+			name:    "escaping and absolute names are skipped",
+			entries: []entry{{name: "../escape.txt", body: "x"}, {name: "a/../../escape.txt", body: "x"}, {name: "/abs.txt", body: "x"}, {name: "ok.txt", body: "ok"}},
+			files:   map[string]string{"ok.txt": "ok"},
+		},
+		{
+			name:    "symlink inside",
+			entries: []entry{{name: "real.txt", body: "r"}, {name: "link.txt", link: "real.txt"}},
+			files:   map[string]string{"link.txt": "r"},
+		},
+		{
+			name:    "absolute symlink",
+			entries: []entry{{name: "link", link: "/etc"}},
+			wantErr: "leaves the destination",
+		},
+		{
+			name:    "escaping symlink",
+			entries: []entry{{name: "a/link", link: "../../outside"}},
+			wantErr: "leaves the destination",
+		},
+		{
+			name:    "write through symlink",
+			entries: []entry{{name: "sub/", dir: true}, {name: "link", link: "sub"}, {name: "link/f.txt", body: "x"}},
+			wantErr: "goes through symlink",
+		},
+		{
+			name:    "file replaces symlink",
+			entries: []entry{{name: "real.txt", body: "r"}, {name: "f.txt", link: "real.txt"}, {name: "f.txt", body: "new"}},
+			files:   map[string]string{"f.txt": "new", "real.txt": "r"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dst := filepath.Join(root, "dst")
+			err := ExtractTar(tarball(t, tt.entries...), dst)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExtractTar() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ExtractTar() error = %v", err)
+			}
+			for p, want := range tt.files {
+				got, err := os.ReadFile(filepath.Join(dst, p))
+				if err != nil || string(got) != want {
+					t.Errorf("%s = %q, %v; want %q", p, got, err, want)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(root, "escape.txt")); err == nil {
+				t.Errorf("entry escaped the destination")
+			}
+		})
+	}
+}
+
+func TestExtractTarSkipLinks(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "dst")
+	var skipped []string
+	err := ExtractTarSkipLinks(tarball(t,
+		entry{name: "abs", link: "/etc/passwd"},
+		entry{name: "a/up", link: "../../outside"},
+		entry{name: "in", link: "f.txt"},
+		entry{name: "f.txt", body: "f"},
+	), dst, func(name, link string) { skipped = append(skipped, name+" -> "+link) })
+	if err != nil {
+		t.Fatalf("ExtractTarSkipLinks() error = %v", err)
+	}
+	if want := []string{"abs -> /etc/passwd", "a/up -> ../../outside"}; strings.Join(skipped, ",") != strings.Join(want, ",") {
+		t.Errorf("skipped = %v, want %v", skipped, want)
+	}
+	for _, p := range []string{"abs", "a/up"} {
+		if _, err := os.Lstat(filepath.Join(dst, p)); err == nil {
+			t.Errorf("%s was created", p)
+		}
+	}
+	if got, err := os.ReadFile(filepath.Join(dst, "in")); err != nil || string(got) != "f" {
+		t.Errorf("in = %q, %v; want the link inside kept", got, err)
+	}
+}
+
+func TestExtractZipRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range map[string]string{"../escape.txt": "x", "ok.txt": "ok"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dst := filepath.Join(dir, "dst")
+	if err := ExtractZip(path, dst); err != nil {
+		t.Fatalf("ExtractZip() error = %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dst, "ok.txt")); err != nil || string(got) != "ok" {
+		t.Errorf("ok.txt = %q, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); err == nil {
+		t.Errorf("entry escaped the destination")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,16 +17,71 @@ type Delims struct {
 }
 
 type Template struct {
-	Repo string `yaml:"repo"`
-	Ref  string `yaml:"ref"`
+	Repo string `yaml:"repo,omitempty"`
+	Ref  string `yaml:"ref,omitempty"`
 	Path string `yaml:"path"`
+	// Local is a directory on disk holding the template, used instead of a Git repo.
+	// A repo written as file://... or as a plain filesystem path is treated the same way.
+	Local string `yaml:"local,omitempty"`
+	// Type forces the source kind: git, http, oci or local. Default: inferred from repo.
+	Type string `yaml:"type,omitempty"`
+	// ArchiveChecksum is the expected SHA-256 (hex) of an http archive download.
+	ArchiveChecksum string `yaml:"archiveChecksum,omitempty"`
 
 	// Optional delimiter override to avoid conflicts with downstream tools (e.g., Taskfile).
 	Delims *Delims `yaml:"delims,omitempty"`
 	// If true, missing keys render as empty strings (zero values). Default: strict error.
 	AllowMissing bool `yaml:"allowMissing,omitempty"`
+	// If true, submodules are fetched recursively. Default: false.
+	Submodules bool `yaml:"submodules,omitempty"`
+	// Shallow clone (--depth 1). Default: true; set false for history-dependent refs.
+	Shallow *bool `yaml:"shallow,omitempty"`
+	// Expected SHA-256 (hex) of the raw template, verified before rendering.
+	Checksum string `yaml:"checksum,omitempty"`
 }
 
+// LocalDir returns the directory of a local template source and true, or false when
+// the template comes from a Git repository. Without template.type, a file:// URL or
+// a plain path in repo is local; the rule is syntactic, so a Git checkout on disk is
+// read as it is unless type is git.
+func (t Template) LocalDir() (string, bool) {
+	if d := strings.TrimSpace(t.Local); d != "" {
+		return d, true
+	}
+	repo := strings.TrimSpace(t.Repo)
+	switch t.Type {
+	case "local":
+		return strings.TrimPrefix(repo, "file://"), true
+	case "":
+	default:
+		return "", false
+	}
+	if strings.HasPrefix(repo, "file://") {
+		return strings.TrimPrefix(repo, "file://"), true
+	}
+	if repo == "" || strings.Contains(repo, "://") {
+		return "", false
+	}
+	// scp-like remotes (git@host:org/repo.git) have a colon before any slash
+	if colon := strings.Index(repo, ":"); colon != -1 {
+		if slash := strings.Index(repo, "/"); slash == -1 || colon < slash {
+			return "", false
+		}
+	}
+	return repo, true
+}
+
+// Source describes where the template comes from, for display and error messages.
+func (t Template) Source() string {
+	if dir, ok := t.LocalDir(); ok {
+		return "local:" + dir
+	}
+	return t.Repo
+}
+
+// IsShallow reports whether the template repository should be cloned shallowly.
+func (t Template) IsShallow() bool { return t.Shallow == nil || *t.Shallow }
+
 // VarKind represents the origin/behavior of a variable value.
 type VarKind int
 
@@ -34,18 +90,76 @@ const (
 	VarEnv                    // !env NAME
 	VarCmd                    // !cmd 'sh expression'
 	VarFile                   // !file path
+	VarMap                    // mapping of nested variables
+	VarList                   // sequence of nested variables
+	VarJSON                   // !json path
+	VarYAML                   // !yaml path
+	VarTOML                   // !toml path
+	VarJSONCmd                // !json-cmd 'sh expression'
+	VarYAMLCmd                // !yaml-cmd 'sh expression'
+	VarTOMLCmd                // !toml-cmd 'sh expression'
 )
 
-// VarValue supports tagged scalars like !env, !cmd, !file as well as plain scalars.
+// dataTags maps the tags of structured-data variables, whose file or command output
+// is parsed into maps and lists, to their kinds.
+var dataTags = map[string]VarKind{
+	"!json": VarJSON, "!yaml": VarYAML, "!toml": VarTOML,
+	"!json-cmd": VarJSONCmd, "!yaml-cmd": VarYAMLCmd, "!toml-cmd": VarTOMLCmd,
+}
+
+// Data reports the format ("json", "yaml" or "toml") of a structured-data kind and
+// whether its argument is a shell command rather than a file path.
+func (k VarKind) Data() (format string, cmd, ok bool) {
+	for tag, kind := range dataTags {
+		if kind == k {
+			format, cmd = strings.CutSuffix(tag[1:], "-cmd")
+			return format, cmd, true
+		}
+	}
+	return "", false, false
+}
+
+// String returns the kind's name as shown by duck list and duck var list.
+func (k VarKind) String() string {
+	switch k {
+	case VarEnv:
+		return "env"
+	case VarCmd:
+		return "cmd"
+	case VarFile:
+		return "file"
+	case VarMap:
+		return "map"
+	case VarList:
+		return "list"
+	}
+	for tag, kind := range dataTags {
+		if kind == k {
+			return tag[1:]
+		}
+	}
+	return "literal"
+}
+
+// VarValue supports tagged scalars like !env, !cmd, !file and the structured-data
+// tags (!json, !yaml-cmd, …) as well as plain scalars, and mappings and sequences
+// whose leaves are any of those. A top-level variable may be declared with a
+// `!var` mapping (see VarSpec).
 // It implements yaml.Unmarshaler to capture custom tags.
 type VarValue struct {
 	Kind  VarKind
-	Arg   string // tag argument (env name, command, or file path)
-	Value any    // for literal
+	Arg   string              // tag argument (env name, command, or file path)
+	Value any                 // for literal
+	Map   map[string]VarValue // for VarMap
+	List  []VarValue          // for VarList
+	Spec  *VarSpec            // declaration, for `!var`
 }
 
 // MarshalYAML enables preserving custom tags when writing config files.
 func (v VarValue) MarshalYAML() (any, error) {
+	if v.Spec != nil {
+		return v.marshalDecl()
+	}
 	switch v.Kind {
 	case VarEnv:
 		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!env", Value: v.Arg}
@@ -56,14 +170,98 @@ func (v VarValue) MarshalYAML() (any, error) {
 	case VarFile:
 		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!file", Value: v.Arg}
 		return n, nil
+	case VarMap:
+		if v.Map == nil {
+			return map[string]VarValue{}, nil
+		}
+		return v.Map, nil
+	case VarList:
+		if v.List == nil {
+			return []VarValue{}, nil
+		}
+		return v.List, nil
 	case VarLiteral:
 		return v.Value, nil
-	default:
-		return v.Value, nil
+	}
+	for tag, kind := range dataTags {
+		if kind == v.Kind {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.Arg}, nil
+		}
+	}
+	return v.Value, nil
+}
+
+// String renders v on one line as it would be written in YAML: a tagged scalar,
+// a literal, or a flow mapping or sequence.
+func (v VarValue) String() string {
+	var n yaml.Node
+	if err := n.Encode(v); err != nil {
+		return fmt.Sprint(v.Value)
+	}
+	flowStyle(&n)
+	b, err := yaml.Marshal(&n)
+	if err != nil {
+		return fmt.Sprint(v.Value)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func flowStyle(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		n.Style = yaml.FlowStyle
+	case yaml.ScalarNode:
+		if strings.Contains(n.Value, "\n") {
+			n.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, c := range n.Content {
+		flowStyle(c)
 	}
 }
 
 func (v *VarValue) UnmarshalYAML(node *yaml.Node) error {
+	// Mappings and sequences nest further variables
+	switch node.Kind {
+	case yaml.AliasNode:
+		return v.UnmarshalYAML(node.Alias)
+	case yaml.MappingNode:
+		if node.Tag == "!var" {
+			return v.unmarshalDecl(node)
+		}
+		if tag := node.ShortTag(); tag != "!!map" {
+			return fmt.Errorf("line %d: tag %s is not allowed on a mapping", node.Line, tag)
+		}
+		v.Kind, v.Map = VarMap, make(map[string]VarValue, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var child VarValue
+			if err := child.UnmarshalYAML(node.Content[i+1]); err != nil {
+				return err
+			}
+			if child.Spec != nil {
+				return fmt.Errorf("line %d: !var is only allowed on top-level variables", node.Content[i+1].Line)
+			}
+			v.Map[node.Content[i].Value] = child
+		}
+		return nil
+	case yaml.SequenceNode:
+		if tag := node.ShortTag(); tag != "!!seq" {
+			return fmt.Errorf("line %d: tag %s is not allowed on a sequence", node.Line, tag)
+		}
+		v.Kind, v.List = VarList, make([]VarValue, 0, len(node.Content))
+		for _, c := range node.Content {
+			var child VarValue
+			if err := child.UnmarshalYAML(c); err != nil {
+				return err
+			}
+			if child.Spec != nil {
+				return fmt.Errorf("line %d: !var is only allowed on top-level variables", c.Line)
+			}
+			v.List = append(v.List, child)
+		}
+		return nil
+	}
+
 	// Custom tags we accept: !env, !cmd, !file
 	switch node.Tag {
 	case "!env":
@@ -76,6 +274,10 @@ func (v *VarValue) UnmarshalYAML(node *yaml.Node) error {
 		v.Kind, v.Arg = VarFile, node.Value
 		return nil
 	}
+	if kind, ok := dataTags[node.Tag]; ok {
+		v.Kind, v.Arg = kind, node.Value
+		return nil
+	}
 
 	// Otherwise, treat as literal and parse basic YAML scalar types
 	v.Kind = VarLiteral
@@ -115,36 +317,87 @@ func (v *VarValue) UnmarshalYAML(node *yaml.Node) error {
 }
 
 type Target struct {
-	Name string `yaml:"name"`
+	Name string `yaml:"name,omitempty"`
 	// Description is an optional human readable explanation printed by `duck list`.
 	Description  string              `yaml:"description,omitempty"`
 	Binary       string              `yaml:"binary,omitempty"`
 	FileFlag     string              `yaml:"fileFlag,omitempty"`
 	Template     Template            `yaml:"template"`
 	Variables    map[string]VarValue `yaml:"variables,omitempty"`
+	VarsFiles    []string            `yaml:"varsFiles,omitempty"` // YAML/JSON/dotenv files merged, in order, below Variables
 	RenderedPath string              `yaml:"renderedPath,omitempty"`
 	Args         ArgList             `yaml:"args,omitempty"`
 }
 
-type DuckConf struct {
-	Version int               `yaml:"version"`
-	Default Target            `yaml:"default"`
-	Targets map[string]Target `yaml:"targets"`
+// Settings holds global switches that apply to every target.
+type Settings struct {
+	// CacheDir is the folder holding rendered objects. Default: .duck/objects.
+	CacheDir string `yaml:"cacheDir,omitempty"`
+	// RepoCacheDir holds shared bare mirrors of template repositories. Environment
+	// variables and a leading ~ are expanded, so it can be user-level
+	// (e.g. ${XDG_CACHE_HOME}/duck/repos). Default: .duck/repos.
+	RepoCacheDir string `yaml:"repoCacheDir,omitempty"`
+	// LogLevel controls CLI verbosity: debug, info, warn or error. Default: info.
+	LogLevel string `yaml:"logLevel,omitempty"`
+	// AllowedHosts restricts template repositories to these hostnames. Empty means no restriction.
+	AllowedHosts []string `yaml:"allowedHosts,omitempty"`
+	// Locked makes duck fail instead of updating when the computed cache key changed.
+	Locked bool `yaml:"locked,omitempty"`
+	// RefreshInterval is how long a ref resolved to a commit SHA is trusted before
+	// asking the remote again (Go duration, e.g. "5m"). "0" always asks. Default: 5m.
+	RefreshInterval string `yaml:"refreshInterval,omitempty"`
 }
 
-// Save writes the configuration to disk as YAML.
-func (c *DuckConf) Save(path string) error {
-	if c.Version == 0 {
-		c.Version = 1
-	}
-	if c.Targets == nil {
-		c.Targets = map[string]Target{}
+// DefaultRefreshInterval is used when settings.refreshInterval is not set.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// RefRefresh returns the parsed refresh interval or the default one.
+func (s Settings) RefRefresh() time.Duration {
+	if strings.TrimSpace(s.RefreshInterval) == "" {
+		return DefaultRefreshInterval
 	}
-	b, err := yaml.Marshal(c)
+	d, err := time.ParseDuration(strings.TrimSpace(s.RefreshInterval))
 	if err != nil {
-		return err
+		return DefaultRefreshInterval
 	}
-	return os.WriteFile(path, b, 0o644)
+	return d
+}
+
+// DefaultCacheDir is used when settings.cacheDir is not set.
+const DefaultCacheDir = ".duck/objects"
+
+// ObjectsDir returns the configured cache directory or the default one.
+func (s Settings) ObjectsDir() string {
+	if d := strings.TrimSpace(s.CacheDir); d != "" {
+		return d
+	}
+	return DefaultCacheDir
+}
+
+// DefaultRepoCacheDir is used when settings.repoCacheDir is not set.
+const DefaultRepoCacheDir = ".duck/repos"
+
+// ReposDir returns the expanded repository cache directory or the default one.
+func (s Settings) ReposDir() string {
+	d := strings.TrimSpace(s.RepoCacheDir)
+	if d == "" {
+		return DefaultRepoCacheDir
+	}
+	d = os.ExpandEnv(d)
+	if d == "~" || strings.HasPrefix(d, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			d = home + d[1:]
+		}
+	}
+	return d
+}
+
+type DuckConf struct {
+	Version   int               `yaml:"version"`
+	VarsFiles []string          `yaml:"varsFiles,omitempty"` // loaded for every target, below the target's own varsFiles
+	Default   Target            `yaml:"default"`
+	Targets   map[string]Target `yaml:"targets"`
+	Settings  Settings          `yaml:"settings,omitempty"`
 }
 
 func Load(path string) (*DuckConf, error) {
@@ -219,10 +472,70 @@ func (c *DuckConf) Validate() error {
 			return err
 		}
 	}
+	if err := validateVarsFiles(c.VarsFiles); err != nil {
+		return fmt.Errorf("varsFiles: %w", err)
+	}
+	return validateSettings(c.Settings)
+}
+
+func validateSettings(s Settings) error {
+	switch strings.ToLower(strings.TrimSpace(s.LogLevel)) {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("settings: invalid logLevel %q (expected debug, info, warn or error)", s.LogLevel)
+	}
+	if ri := strings.TrimSpace(s.RefreshInterval); ri != "" {
+		if d, err := time.ParseDuration(ri); err != nil || d < 0 {
+			return fmt.Errorf("settings: invalid refreshInterval %q (expected a duration such as 30s or 5m)", s.RefreshInterval)
+		}
+	}
+	for _, h := range s.AllowedHosts {
+		if strings.TrimSpace(h) == "" {
+			return fmt.Errorf("settings: allowedHosts must not contain empty entries")
+		}
+	}
 	return nil
 }
 
 func validateTarget(t Target, name string) error {
+	hasRepo, hasLocal := strings.TrimSpace(t.Template.Repo) != "", strings.TrimSpace(t.Template.Local) != ""
+	if hasRepo && hasLocal {
+		return fmt.Errorf("target %q: template.repo and template.local are mutually exclusive", name)
+	}
+	if !hasRepo && !hasLocal {
+		return fmt.Errorf("target %q: template.repo or template.local is required", name)
+	}
+	switch t.Template.Type {
+	case "", "git", "http", "oci", "local":
+	default:
+		return fmt.Errorf("target %q: invalid template.type %q (expected git, http, oci or local)", name, t.Template.Type)
+	}
+	if _, local := t.Template.LocalDir(); local {
+		switch {
+		case strings.TrimSpace(t.Template.Ref) != "":
+			return fmt.Errorf("target %q: template.ref is not allowed for a local template (set template.type: git to fetch a repository on disk with Git)", name)
+		case t.Template.Shallow != nil:
+			return fmt.Errorf("target %q: template.shallow is not allowed for a local template (set template.type: git to fetch a repository on disk with Git)", name)
+		case t.Template.Submodules:
+			return fmt.Errorf("target %q: template.submodules is not allowed for a local template (set template.type: git to fetch a repository on disk with Git)", name)
+		}
+	}
+	if c := strings.TrimSpace(t.Template.ArchiveChecksum); c != "" && !isSHA256Hex(c) {
+		return fmt.Errorf("target %q: template.archiveChecksum must be a 64-character hex SHA-256", name)
+	}
+	if c := strings.TrimSpace(t.Template.Checksum); c != "" && !isSHA256Hex(c) {
+		return fmt.Errorf("target %q: template.checksum must be a 64-character hex SHA-256", name)
+	}
+	if err := validateVarsFiles(t.VarsFiles); err != nil {
+		return fmt.Errorf("target %q: varsFiles: %w", name, err)
+	}
+	for k, v := range t.Variables {
+		if v.Spec != nil {
+			if err := validateVarSpec(k, v.Spec); err != nil {
+				return fmt.Errorf("target %q: %w", name, err)
+			}
+		}
+	}
 	hasBin := strings.TrimSpace(t.Binary) != ""
 	if !hasBin {
 		if strings.TrimSpace(t.FileFlag) != "" {
@@ -235,11 +548,47 @@ func validateTarget(t Target, name string) error {
 	return nil
 }
 
+func validateVarsFiles(files []string) error {
+	for _, f := range files {
+		if strings.TrimSpace(f) == "" {
+			return fmt.Errorf("must not contain empty entries")
+		}
+		if _, err := varsFileFormat(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
 // NewLiteralVar helper.
 func NewLiteralVar(val any) VarValue  { return VarValue{Kind: VarLiteral, Value: val} }
 func NewEnvVar(name string) VarValue  { return VarValue{Kind: VarEnv, Arg: name} }
 func NewCmdVar(cmd string) VarValue   { return VarValue{Kind: VarCmd, Arg: cmd} }
 func NewFileVar(path string) VarValue { return VarValue{Kind: VarFile, Arg: path} }
 
+// ParseLiteralVar reads s the way a plain YAML scalar is read, so "8080" becomes an
+// int and "true" a bool; anything else stays a string.
+func ParseLiteralVar(s string) VarValue {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Value: s}
+	n.Tag = n.ShortTag()
+	var v VarValue
+	if err := v.UnmarshalYAML(n); err != nil {
+		return NewLiteralVar(s)
+	}
+	return v
+}
+
 // ValidateTarget exposes target validation rules for external callers.
 func ValidateTarget(t Target, name string) error { return validateTarget(t, name) }
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateLocalDir(t *testing.T) {
+	// A Git checkout on disk must not change how a path is read
+	checkout := t.TempDir()
+	if err := os.Mkdir(filepath.Join(checkout, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tpl       Template
+		wantDir   string
+		wantLocal bool
+	}{
+		{tpl: Template{Local: "./templates"}, wantDir: "./templates", wantLocal: true},
+		{tpl: Template{Repo: "."}, wantDir: ".", wantLocal: true},
+		{tpl: Template{Repo: "../templates"}, wantDir: "../templates", wantLocal: true},
+		{tpl: Template{Repo: checkout}, wantDir: checkout, wantLocal: true},
+		{tpl: Template{Repo: "file://" + checkout}, wantDir: checkout, wantLocal: true},
+		{tpl: Template{Repo: checkout, Type: "git"}},
+		{tpl: Template{Repo: "file://" + checkout, Type: "local"}, wantDir: checkout, wantLocal: true},
+		{tpl: Template{Repo: "https://github.com/org/tpl.git"}},
+		{tpl: Template{Repo: "git@github.com:org/tpl.git"}},
+		{tpl: Template{Repo: "github.com:org/tpl"}},
+		{tpl: Template{Repo: "oci://ghcr.io/org/tpl"}},
+	}
+	for _, tt := range tests {
+		dir, local := tt.tpl.LocalDir()
+		if dir != tt.wantDir || local != tt.wantLocal {
+			t.Errorf("LocalDir(%+v) = %q, %v; want %q, %v", tt.tpl, dir, local, tt.wantDir, tt.wantLocal)
+		}
+	}
+}
+
+func TestValidateTargetLocalTemplate(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name    string
+		tpl     Template
+		wantErr string
+	}{
+		{name: "local", tpl: Template{Local: "tpl", Path: "a.tpl"}},
+		{name: "path with ref", tpl: Template{Repo: ".", Path: "a.tpl", Ref: "v1"}, wantErr: "template.ref is not allowed for a local template"},
+		{name: "local with shallow", tpl: Template{Local: "tpl", Path: "a.tpl", Shallow: &yes}, wantErr: "template.shallow is not allowed"},
+		{name: "file url with submodules", tpl: Template{Repo: "file:///tpl", Path: "a.tpl", Submodules: true}, wantErr: "template.submodules is not allowed"},
+		{name: "path as git with ref", tpl: Template{Repo: ".", Type: "git", Path: "a.tpl", Ref: "v1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTarget(Target{Template: tt.tpl}, "x")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateTarget() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateTarget() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,721 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/CyberDuck79/duckfile/internal/fsutil"
+)
+
+// Document is duck.yaml as a yaml.Node tree. Edits touch only the affected nodes,
+// so comments, key order and sections duck does not know about survive a save,
+// unlike DuckConf.Save which re-marshals the whole struct.
+type Document struct {
+	root yaml.Node
+	src  []byte
+}
+
+// yaml.v3 drops blank lines and the alignment of trailing comments but keeps
+// comments, so both are carried through encoding as comment markers: the blank
+// marker is a head comment standing for a blank line, the col marker prefixes a
+// line comment with the column it started at. Flow collections are swapped for
+// flow token scalars and rendered separately to keep their `{ a: b }` padding.
+// Markers get a suffix that occurs nowhere in the document, so no user value or
+// comment is ever mistaken for one.
+type layoutMarkers struct {
+	blank string // "#duck:blank-line"
+	col   string // "#duck:col="
+	flow  string // "duck-flow-"
+}
+
+// newLayoutMarkers returns markers found neither in src nor in the tree under n,
+// which may hold values added by edits.
+func newLayoutMarkers(src []byte, n *yaml.Node) layoutMarkers {
+	for i := 0; ; i++ {
+		suffix := ""
+		if i > 0 {
+			suffix = "." + strconv.Itoa(i)
+		}
+		m := layoutMarkers{blank: "#duck:blank-line" + suffix, col: "#duck:col" + suffix + "=", flow: "duck-flow" + suffix + "-"}
+		if !m.occursIn(string(src)) && !m.occursInNode(n) {
+			return m
+		}
+	}
+}
+
+func (m layoutMarkers) occursIn(s string) bool {
+	return strings.Contains(s, m.blank) || strings.Contains(s, m.col) || strings.Contains(s, m.flow)
+}
+
+func (m layoutMarkers) occursInNode(n *yaml.Node) bool {
+	for _, s := range []string{n.Value, n.Tag, n.Anchor, n.HeadComment, n.LineComment, n.FootComment} {
+		if m.occursIn(s) {
+			return true
+		}
+	}
+	for _, c := range n.Content {
+		if m.occursInNode(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadDocument parses path for editing.
+func LoadDocument(path string) (*Document, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocument(src)
+}
+
+// ParseDocument parses YAML source for editing. Empty input yields an empty mapping.
+func ParseDocument(src []byte) (*Document, error) {
+	d := &Document{src: src}
+	if err := yaml.Unmarshal(src, &d.root); err != nil {
+		return nil, err
+	}
+	if d.root.Kind == 0 {
+		d.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if d.top().Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("duck.yaml: top level must be a mapping")
+	}
+	return d, nil
+}
+
+func (d *Document) top() *yaml.Node { return d.root.Content[0] }
+
+// Bytes encodes the document with two-space indentation, restoring the blank
+// lines and trailing-comment alignment of the original source.
+func (d *Document) Bytes() ([]byte, error) {
+	lines := strings.Split(string(d.src), "\n")
+	mk := newLayoutMarkers(d.src, &d.root)
+	mk.mark(d.top(), lines)
+	fs := &flowSwapper{token: mk.flow, lines: lines, pad: strings.Contains(string(d.src), "{ ")}
+	fs.swap(d.top())
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err := enc.Encode(&d.root)
+	fs.restore()
+	mk.unmark(d.top())
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.TrimSpace(line) == mk.blank {
+			out.WriteString("\n")
+			continue
+		}
+		out.WriteString(mk.align(fs.expand(line)))
+	}
+	return out.Bytes(), nil
+}
+
+// Save writes the document to path atomically.
+func (d *Document) Save(path string) error {
+	b, err := d.Bytes()
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, b, 0o644)
+}
+
+// mark prefixes the head comment of every mapping key and sequence item that
+// followed a blank line in the source with the blank marker, and the line comment
+// of every node with its original column. Nodes added by edits have no position
+// and are left alone.
+func (m layoutMarkers) mark(n *yaml.Node, lines []string) {
+	if n.LineComment != "" && n.Line > 0 && n.Line <= len(lines) {
+		if col := strings.LastIndex(lines[n.Line-1], n.LineComment); col > 0 {
+			n.LineComment = m.col + strconv.Itoa(col) + n.LineComment
+		}
+	}
+	step := 1
+	if n.Kind == yaml.MappingNode {
+		step = 2
+	}
+	separated := false // whether the previous entry followed a blank line
+	for i := step; i < len(n.Content); i += step {
+		item := n.Content[i]
+		if item.Line == 0 {
+			// New entry: separate it like its predecessor
+			if separated {
+				item.HeadComment = strings.TrimSuffix(m.blank+"\n"+item.HeadComment, "\n")
+			}
+			continue
+		}
+		above := item.Line - 1 - strings.Count(item.HeadComment, "\n")
+		if item.HeadComment != "" {
+			above--
+		}
+		separated = above >= 1 && above <= len(lines) && strings.TrimSpace(lines[above-1]) == ""
+		if separated {
+			item.HeadComment = strings.TrimSuffix(m.blank+"\n"+item.HeadComment, "\n")
+		}
+	}
+	for _, c := range n.Content {
+		m.mark(c, lines)
+	}
+}
+
+func (m layoutMarkers) unmark(n *yaml.Node) {
+	n.HeadComment = strings.TrimPrefix(strings.TrimPrefix(n.HeadComment, m.blank), "\n")
+	if rest, ok := strings.CutPrefix(n.LineComment, m.col); ok {
+		n.LineComment = strings.TrimLeft(rest, "0123456789")
+	}
+	for _, c := range n.Content {
+		m.unmark(c)
+	}
+}
+
+// align moves a marked line comment back to its original column.
+func (m layoutMarkers) align(line string) string {
+	i := strings.Index(line, " "+m.col)
+	if i == -1 {
+		return line
+	}
+	rest := line[i+1+len(m.col):]
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	col, _ := strconv.Atoi(rest[:digits])
+	code := line[:i]
+	if pad := col - len(code); pad > 1 {
+		code += strings.Repeat(" ", pad-1)
+	}
+	return code + " " + rest[digits:]
+}
+
+// flowSwapper replaces outermost flow collections by token scalars during encoding
+// and renders them itself, padded like the original (`{ a: b }` vs `{a: b}`).
+type flowSwapper struct {
+	token    string // flow marker the swapped scalars are numbered after
+	lines    []string
+	pad      bool // padding for collections added by edits: follow the file's habit
+	rendered []string
+	swapped  []flowSwap
+}
+
+type flowSwap struct {
+	parent *yaml.Node
+	i      int
+	orig   *yaml.Node
+}
+
+func (f *flowSwapper) swap(n *yaml.Node) {
+	for i, c := range n.Content {
+		if (c.Kind != yaml.MappingNode && c.Kind != yaml.SequenceNode) || c.Style&yaml.FlowStyle == 0 {
+			f.swap(c)
+			continue
+		}
+		tok := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.token + strconv.Itoa(len(f.rendered)), Anchor: c.Anchor,
+			HeadComment: c.HeadComment, LineComment: c.LineComment, FootComment: c.FootComment}
+		f.rendered = append(f.rendered, collectionTag(c)+f.render(c))
+		f.swapped = append(f.swapped, flowSwap{parent: n, i: i, orig: c})
+		n.Content[i] = tok
+	}
+}
+
+func (f *flowSwapper) restore() {
+	for _, s := range f.swapped {
+		s.parent.Content[s.i] = s.orig
+	}
+}
+
+// expand substitutes a token on an encoded line with its rendered collection.
+func (f *flowSwapper) expand(line string) string {
+	i := strings.Index(line, f.token)
+	if i == -1 {
+		return line
+	}
+	rest := line[i+len(f.token):]
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	n, err := strconv.Atoi(rest[:digits])
+	if err != nil || n >= len(f.rendered) {
+		return line
+	}
+	return line[:i] + f.rendered[n] + rest[digits:]
+}
+
+// padded reports whether a flow collection had a space inside its opening bracket.
+func (f *flowSwapper) padded(n *yaml.Node) bool {
+	if n.Line < 1 || n.Line > len(f.lines) {
+		return f.pad
+	}
+	line := f.lines[n.Line-1]
+	i := strings.IndexAny(line[min(n.Column-1, len(line)):], "{[") // skip an anchor or tag
+	if i == -1 {
+		return f.pad
+	}
+	i += n.Column
+	return i < len(line) && line[i] == ' '
+}
+
+func (f *flowSwapper) render(n *yaml.Node) string {
+	var parts []string
+	switch n.Kind {
+	case yaml.AliasNode:
+		return "*" + n.Value
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			parts = append(parts, f.render(n.Content[i])+": "+f.renderItem(n.Content[i+1]))
+		}
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			parts = append(parts, f.renderItem(c))
+		}
+	default:
+		c := *n
+		c.HeadComment, c.LineComment, c.FootComment = "", "", ""
+		if strings.Contains(c.Value, "\n") {
+			c.Style = yaml.DoubleQuotedStyle
+		}
+		b, err := yaml.Marshal(&c)
+		if err != nil {
+			return c.Value
+		}
+		return strings.TrimSuffix(string(b), "\n")
+	}
+	open, close := "{", "}"
+	if n.Kind == yaml.SequenceNode {
+		open, close = "[", "]"
+	}
+	if len(parts) == 0 {
+		return open + close
+	}
+	if f.padded(n) {
+		return open + " " + strings.Join(parts, ", ") + " " + close
+	}
+	return open + strings.Join(parts, ", ") + close
+}
+
+// renderItem renders a nested collection with its anchor and tag (scalars keep
+// theirs when marshalled).
+func (f *flowSwapper) renderItem(n *yaml.Node) string {
+	if n.Kind != yaml.MappingNode && n.Kind != yaml.SequenceNode {
+		return f.render(n)
+	}
+	if n.Anchor != "" {
+		return "&" + n.Anchor + " " + collectionTag(n) + f.render(n)
+	}
+	return collectionTag(n) + f.render(n)
+}
+
+// collectionTag returns the custom tag of a collection, such as "!var ", to write
+// before it; standard tags are implied.
+func collectionTag(n *yaml.Node) string {
+	if n.Tag == "" || strings.HasPrefix(n.Tag, "!!") {
+		return ""
+	}
+	return n.Tag + " "
+}
+
+// Target returns the mapping node of a target: the top-level default for
+// "default" (or an empty name), targets.<name> otherwise.
+func (d *Document) Target(name string) (*yaml.Node, error) {
+	if name == "" || name == "default" {
+		if n := mappingValue(d.top(), "default"); n != nil && n.Kind == yaml.MappingNode {
+			return n, nil
+		}
+		return nil, fmt.Errorf("duck.yaml has no default target")
+	}
+	if targets := mappingValue(d.top(), "targets"); targets != nil {
+		if n := mappingValue(targets, name); n != nil && n.Kind == yaml.MappingNode {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown target %q", name)
+}
+
+// NewDocument returns an empty duck.yaml declaring the current config version.
+func NewDocument() *Document {
+	d, _ := ParseDocument(nil)
+	insertPair(d.top(), scalar("version"), &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: "1"}, "")
+	return d
+}
+
+// SetDefault sets the default target, replacing an existing one.
+func (d *Document) SetDefault(t Target) error {
+	n, err := encodeNode(t)
+	if err != nil {
+		return err
+	}
+	if old := mappingValue(d.top(), "default"); old != nil {
+		n.HeadComment, n.LineComment, n.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		*old = *n
+		return nil
+	}
+	insertPair(d.top(), scalar("default"), n, "version")
+	return nil
+}
+
+// AddTarget adds targets.<name>, creating the targets section after the default
+// target when the file has none. It fails if the target already exists.
+func (d *Document) AddTarget(name string, t Target) error {
+	if name == "" || name == "default" {
+		return fmt.Errorf("invalid target name %q", name)
+	}
+	targets := mappingValue(d.top(), "targets")
+	if targets == nil || targets.Kind != yaml.MappingNode {
+		if targets != nil && !(targets.Kind == yaml.ScalarNode && targets.Tag == "!!null") {
+			return fmt.Errorf("duck.yaml: targets must be a mapping")
+		}
+		fresh := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if targets != nil {
+			*targets = *fresh
+		} else {
+			insertPair(d.top(), scalar("targets"), fresh, "default")
+		}
+		targets = mappingValue(d.top(), "targets")
+	}
+	if mappingValue(targets, name) != nil {
+		return fmt.Errorf("target %s already exists", name)
+	}
+	n, err := encodeNode(t)
+	if err != nil {
+		return err
+	}
+	insertPair(targets, scalar(name), n, "")
+	return nil
+}
+
+// UpdateTarget writes t over an existing target, changing only the entries that
+// differ: comments stay attached, untouched keys keep their position and style,
+// and keys duck does not know about are left alone.
+func (d *Document) UpdateTarget(name string, t Target) error {
+	n, err := d.Target(name)
+	if err != nil {
+		return err
+	}
+	src, err := encodeNode(t)
+	if err != nil {
+		return err
+	}
+	mergeNode(n, src, reflect.TypeOf(t))
+	return nil
+}
+
+// RemoveTarget deletes targets.<name>. The default target cannot be removed.
+func (d *Document) RemoveTarget(name string) error {
+	if name == "" || name == "default" {
+		return fmt.Errorf("the default target cannot be removed")
+	}
+	if _, err := d.Target(name); err != nil {
+		return err
+	}
+	removeKey(mappingValue(d.top(), "targets"), name)
+	return nil
+}
+
+// SetVariable sets variables.<key> of a target, creating the variables mapping
+// when the target has none. An existing entry keeps its comments and position, and
+// a `!var` declaration keeps everything but its value.
+func (d *Document) SetVariable(target, key string, v VarValue) error {
+	t, err := d.Target(target)
+	if err != nil {
+		return err
+	}
+	if cur := mappingValue(mappingValue(t, "variables"), key); cur != nil && cur.Tag == "!var" && v.Spec == nil {
+		var old VarValue
+		if err := cur.Decode(&old); err != nil {
+			return err
+		}
+		v.Spec = old.Spec
+	}
+	n, err := encodeNode(v)
+	if err != nil {
+		return err
+	}
+	vars := mappingValue(t, "variables")
+	switch {
+	case vars == nil:
+		m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		insertPair(m, scalar(key), n, "")
+		insertPair(t, scalar("variables"), m, "")
+	case vars.Kind == yaml.ScalarNode && vars.Tag == "!!null":
+		*vars = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", LineComment: vars.LineComment}
+		insertPair(vars, scalar(key), n, "")
+	case vars.Kind != yaml.MappingNode:
+		return fmt.Errorf("target %q: variables must be a mapping", target)
+	default:
+		if cur := mappingValue(vars, key); cur != nil {
+			mergeNode(cur, n, reflect.TypeOf(v))
+		} else {
+			insertPair(vars, scalar(key), n, "")
+		}
+	}
+	return nil
+}
+
+// UnsetVariable deletes variables.<key> of a target, and the variables mapping
+// itself once it is empty.
+func (d *Document) UnsetVariable(target, key string) error {
+	t, err := d.Target(target)
+	if err != nil {
+		return err
+	}
+	vars := mappingValue(t, "variables")
+	if mappingValue(vars, key) == nil {
+		return fmt.Errorf("target %q has no variable %q", target, key)
+	}
+	removeKey(vars, key)
+	if len(vars.Content) == 0 {
+		removeKey(t, "variables")
+	}
+	return nil
+}
+
+// SetTemplateRef sets template.ref of a target, adding the key after
+// template.repo when it is missing.
+func (d *Document) SetTemplateRef(target, ref string) error {
+	t, err := d.Target(target)
+	if err != nil {
+		return err
+	}
+	tpl := mappingValue(t, "template")
+	if tpl == nil || tpl.Kind != yaml.MappingNode {
+		return fmt.Errorf("target %q has no template mapping", target)
+	}
+	setScalar(tpl, "ref", ref, "repo")
+	return nil
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// encodeNode converts v into a block-style node tree.
+func encodeNode(v any) (*yaml.Node, error) {
+	var n yaml.Node
+	if err := n.Encode(v); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// mappingValue returns the value node of key in mapping m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// removeKey deletes key and its value, comments included, from mapping m.
+func removeKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// mergeNode updates dst in place to hold the data of src, which encodes a value of
+// type t. Struct keys absent from src are removed only when they are fields of t, so
+// unknown keys survive; map entries absent from src are removed.
+func mergeNode(dst, src *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(VarValue{}) {
+		t = reflect.TypeOf(map[string]VarValue(nil)) // a structured variable nests variables
+	}
+	if dst.Kind == yaml.AliasNode {
+		if !sameValue(dst, src) {
+			replaceNode(dst, src)
+		}
+		return // keep *alias while it still holds the same data
+	}
+	if dst.Kind == yaml.ScalarNode && src.Kind == yaml.SequenceNode && len(src.Content) == 1 && src.Content[0].Value == dst.Value {
+		return // `args: --x` and `args: [--x]` are the same list
+	}
+	if dst.Kind != src.Kind || (dst.Kind == yaml.ScalarNode && dst.Tag != src.Tag && !isStrTag(dst.Tag, src.Tag)) {
+		replaceNode(dst, src)
+		return
+	}
+	switch dst.Kind {
+	case yaml.ScalarNode:
+		if dst.Value != src.Value {
+			dst.Value = src.Value
+			dst.Style = scalarStyle(src.Value, dst.Style&^yaml.FlowStyle)
+		}
+		if src.Tag != "!!str" {
+			dst.Tag = src.Tag
+		}
+	case yaml.SequenceNode:
+		switch {
+		case sameScalars(dst, src):
+		case len(dst.Content) == len(src.Content):
+			for i := range dst.Content {
+				mergeNode(dst.Content[i], src.Content[i], elemType(t))
+			}
+		default:
+			dst.Content = src.Content
+		}
+	case yaml.MappingNode:
+		known := yamlKeys(t)
+		prev := ""
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, val := src.Content[i].Value, src.Content[i+1]
+			if cur := mappingValue(dst, key); cur != nil {
+				mergeNode(cur, val, fieldType(t, key))
+			} else {
+				insertPair(dst, scalar(key), val, prev)
+			}
+			prev = key
+		}
+		for i := 0; i+1 < len(dst.Content); {
+			key := dst.Content[i].Value
+			if mappingValue(src, key) == nil && (t.Kind() == reflect.Map || known[key]) {
+				dst.Content = append(dst.Content[:i], dst.Content[i+2:]...)
+				continue
+			}
+			i += 2
+		}
+	}
+}
+
+// replaceNode overwrites dst with src, keeping the comments attached to dst.
+func replaceNode(dst, src *yaml.Node) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+}
+
+// isStrTag reports whether a plain scalar and a string scalar hold the same kind of
+// value, e.g. a quoted "1" in the file and an encoded string.
+func isStrTag(a, b string) bool {
+	return (a == "!!str" || a == "") && (b == "!!str" || b == "")
+}
+
+// sameValue reports whether two nodes decode to the same data.
+func sameValue(a, b *yaml.Node) bool {
+	var va, vb any
+	if a.Decode(&va) != nil || b.Decode(&vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func sameScalars(a, b *yaml.Node) bool {
+	if len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if a.Content[i].Kind != yaml.ScalarNode || b.Content[i].Kind != yaml.ScalarNode || a.Content[i].Value != b.Content[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlKeys returns the YAML keys of struct type t.
+func yamlKeys(t reflect.Type) map[string]bool {
+	keys := map[string]bool{}
+	if t.Kind() != reflect.Struct {
+		return keys
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlName(t.Field(i)); name != "" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// elemType returns the element type of a slice or map type.
+func elemType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		return t.Elem()
+	}
+	return reflect.TypeOf("")
+}
+
+// fieldType returns the type stored under key: the struct field of that YAML name
+// or the map element type.
+func fieldType(t reflect.Type, key string) reflect.Type {
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if yamlName(t.Field(i)) == key {
+				return t.Field(i).Type
+			}
+		}
+	}
+	return reflect.TypeOf("")
+}
+
+func yamlName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// setScalar sets key in mapping m to a string scalar, keeping the existing node
+// (and its comments) when present. A new key is inserted after the key named
+// after, or appended when after is empty or absent.
+func setScalar(m *yaml.Node, key, value, after string) {
+	if v := mappingValue(m, key); v != nil && v.Kind == yaml.ScalarNode {
+		v.Value, v.Tag = value, "!!str"
+		v.Style = scalarStyle(value, v.Style)
+		return
+	}
+	v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	v.Style = scalarStyle(value, 0)
+	insertPair(m, scalar(key), v, after)
+}
+
+// insertPair adds key: value to mapping m after the key named after (or at the end).
+func insertPair(m *yaml.Node, key, value *yaml.Node, after string) {
+	pos := len(m.Content)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if after != "" && m.Content[i].Value == after {
+			pos = i + 2
+			break
+		}
+	}
+	m.Content = append(m.Content[:pos], append([]*yaml.Node{key, value}, m.Content[pos:]...)...)
+}
+
+// scalarStyle quotes values that would not read back as the same string
+// (e.g. "^2.3" is fine plain, but "1.10" or "true" would change type).
+func scalarStyle(value string, current yaml.Style) yaml.Style {
+	if current&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		return current
+	}
+	var probe any
+	if err := yaml.Unmarshal([]byte(value), &probe); err != nil {
+		return yaml.DoubleQuotedStyle
+	}
+	if s, ok := probe.(string); !ok || s != value {
+		return yaml.DoubleQuotedStyle
+	}
+	return 0
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "comments and blank lines",
+			src: `# duck config
+version: 1
+
+default:
+  name: build # the main target
+  template:
+    repo: https://github.com/org/templates.git   # aligned comment
+    path: Makefile.tpl
+
+  # variables follow a blank line
+  variables:
+    GO_VERSION: "1.22"
+`,
+		},
+		{
+			name: "flow collections",
+			src: `version: 1
+default:
+  name: build
+  template: { repo: https://x/t.git, path: a.tpl, delims: { left: "[[", right: "]]" } }
+  fileFlag: -f
+  args: [--x, --y]
+  variables:
+    PORT: !var { value: !env PORT, type: int }
+    LIST: [a, b]
+`,
+		},
+		{
+			name: "anchors and aliases",
+			src: `version: 1
+default:
+  name: build
+  template: &tpl
+    repo: https://x/t.git
+    path: a.tpl
+targets:
+  lint:
+    template: *tpl
+    variables: &vars {A: "1"}
+  test:
+    template: *tpl
+    variables: *vars
+`,
+		},
+		{
+			name: "values that look like layout markers",
+			src: `version: 1
+default:
+  name: build
+  template: {left: a}
+  variables:
+    A: duck-flow-0
+    B: "#duck:blank-line"
+    C: x # #duck:col=40 not a marker
+    D: duck-flow.1-0
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDocument([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("ParseDocument() error = %v", err)
+			}
+			got, err := d.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes() error = %v", err)
+			}
+			if string(got) != tt.src {
+				t.Errorf("round trip changed the document:\n--- got\n%s--- want\n%s", got, tt.src)
+			}
+		})
+	}
+}
+
+func TestDocumentEditKeepsMarkerLikeValues(t *testing.T) {
+	src := `version: 1
+default:
+  name: build
+  template: { repo: https://x/t.git, path: a.tpl }
+
+  variables:
+    A: duck-flow-0 # keep me
+`
+	d, err := ParseDocument([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetVariable("default", "B", VarValue{Kind: VarLiteral, Value: "duck-flow-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetVariable("default", "C", VarValue{Kind: VarLiteral, Value: "#duck:blank-line"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := src + `    B: duck-flow-1
+    C: '#duck:blank-line'
+`
+	if string(got) != want {
+		t.Errorf("SetVariable() output:\n--- got\n%s--- want\n%s", got, want)
+	}
+	var cfg DuckConf
+	if err := yaml.Unmarshal(got, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.Default.Variables["A"].Value; v != "duck-flow-0" {
+		t.Errorf("A = %v, want duck-flow-0", v)
+	}
+}
+
+func TestDocumentEdits(t *testing.T) {
+	src := `version: 1
+
+default:
+  name: build # main
+  template:
+    repo: https://x/t.git
+    path: a.tpl
+
+targets:
+  # linting
+  lint:
+    template: { repo: https://x/t.git, path: lint.tpl }
+  test:
+    template:
+      repo: https://x/t.git
+      path: test.tpl
+`
+	tests := []struct {
+		name string
+		edit func(d *Document) error
+		want string
+	}{
+		{
+			name: "remove target",
+			edit: func(d *Document) error { return d.RemoveTarget("test") },
+			want: strings.TrimSuffix(src, `  test:
+    template:
+      repo: https://x/t.git
+      path: test.tpl
+`),
+		},
+		{
+			name: "set ref",
+			edit: func(d *Document) error { return d.SetTemplateRef("lint", "v1") },
+			want: strings.Replace(src, "t.git, path: lint.tpl }", "t.git, ref: v1, path: lint.tpl }", 1),
+		},
+		{
+			name: "unset missing variable",
+			edit: func(d *Document) error {
+				if err := d.UnsetVariable("default", "X"); err == nil {
+					t.Error("UnsetVariable() of a missing variable: want error")
+				}
+				return nil
+			},
+			want: src,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDocument([]byte(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.edit(d); err != nil {
+				t.Fatal(err)
+			}
+			got, err := d.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("edited document:\n--- got\n%s--- want\n%s", got, tt.want)
+			}
+		})
+	}
+}
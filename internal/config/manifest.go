@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the repository-wide template manifest, read from the root of a
+// template source when a template has no manifest of its own.
+const ManifestFile = "duck-template.yaml"
+
+// ManifestSuffix names a template's own manifest: Makefile.tpl is described by
+// Makefile.tpl.duck.yaml next to it.
+const ManifestSuffix = ".duck.yaml"
+
+// Manifest is the contract a template publishes to the targets that render it:
+// the variables it expects, declared like `!var` with literal defaults.
+//
+//	description: Go service Makefile
+//	variables:
+//	  GO_VERSION:
+//	    type: string
+//	    required: true
+//	    description: Go toolchain version
+//	  PORT: { type: int, default: 8080 }
+type Manifest struct {
+	Path        string                 `yaml:"-"` // file read, relative to the template source
+	Description string                 `yaml:"description,omitempty"`
+	Variables   map[string]ManifestVar `yaml:"variables,omitempty"`
+}
+
+// ManifestVar declares a variable in a manifest. Unlike a `!var` declaration its
+// default is a plain value: a manifest comes from the template's source, so it may
+// not read the consumer's environment, files or run commands.
+type ManifestVar struct {
+	Type        string `yaml:"type,omitempty"`
+	Default     any    `yaml:"default,omitempty"`
+	Required    bool   `yaml:"required,omitempty"`
+	Enum        []any  `yaml:"enum,omitempty"`
+	Pattern     string `yaml:"pattern,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// Spec returns the declaration of v, with its default as a literal variable.
+func (v ManifestVar) Spec() VarSpec {
+	s := VarSpec{Type: v.Type, Required: v.Required, Enum: v.Enum, Pattern: v.Pattern, Description: v.Description}
+	if v.Default != nil {
+		def := literalVar(v.Default)
+		s.Default = &def
+	}
+	return s
+}
+
+// literalVar wraps a decoded YAML value in a VarValue of literals, with integers
+// as int64 like the ones read from duck.yaml.
+func literalVar(v any) VarValue {
+	switch t := v.(type) {
+	case map[string]any:
+		m := make(map[string]VarValue, len(t))
+		for k, c := range t {
+			m[k] = literalVar(c)
+		}
+		return VarValue{Kind: VarMap, Map: m}
+	case map[any]any:
+		m := make(map[string]VarValue, len(t))
+		for k, c := range t {
+			m[fmt.Sprint(k)] = literalVar(c)
+		}
+		return VarValue{Kind: VarMap, Map: m}
+	case []any:
+		l := make([]VarValue, len(t))
+		for i, c := range t {
+			l[i] = literalVar(c)
+		}
+		return VarValue{Kind: VarList, List: l}
+	case int:
+		return VarValue{Kind: VarLiteral, Value: int64(t)}
+	case uint64:
+		return VarValue{Kind: VarLiteral, Value: float64(t)}
+	}
+	return VarValue{Kind: VarLiteral, Value: v}
+}
+
+// rejectTags fails on the first custom tag (!env, !cmd, !file...) under n.
+func rejectTags(n *yaml.Node) error {
+	if n.Kind != yaml.DocumentNode && n.Kind != yaml.AliasNode && n.Tag != "" && !strings.HasPrefix(n.Tag, "!!") {
+		return fmt.Errorf("line %d: tag %s is not allowed; manifest values are literal", n.Line, n.Tag)
+	}
+	for _, c := range n.Content {
+		if err := rejectTags(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadManifest reads the manifest of the template at tplPath inside the source
+// directory dir: <tplPath>.duck.yaml, else duck-template.yaml at the root of dir.
+// It returns nil when there is neither.
+func LoadManifest(dir, tplPath string) (*Manifest, error) {
+	for _, name := range []string{tplPath + ManifestSuffix, ManifestFile} {
+		raw, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read template manifest: %w", err)
+		}
+		m := &Manifest{Path: path.Clean(name)}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("parse template manifest %s: %w", m.Path, err)
+		}
+		if err := rejectTags(&doc); err != nil {
+			return nil, fmt.Errorf("template manifest %s: %w", m.Path, err)
+		}
+		if err := doc.Decode(m); err != nil {
+			return nil, fmt.Errorf("parse template manifest %s: %w", m.Path, err)
+		}
+		for k, v := range m.Variables {
+			spec := v.Spec()
+			if err := validateVarSpec(k, &spec); err != nil {
+				return nil, fmt.Errorf("template manifest %s: %w", m.Path, err)
+			}
+		}
+		return m, nil
+	}
+	return nil, nil
+}
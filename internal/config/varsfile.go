@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// varsFileFormat returns the format of a vars file from its name: "yaml", "json"
+// or "dotenv" (.env, or any name ending in .env such as prod.env).
+func varsFileFormat(path string) (string, error) {
+	base := filepath.Base(path)
+	switch ext := strings.ToLower(filepath.Ext(base)); {
+	case ext == ".yaml" || ext == ".yml":
+		return "yaml", nil
+	case ext == ".json":
+		return "json", nil
+	case ext == ".env" || strings.HasPrefix(base, ".env"):
+		return "dotenv", nil
+	}
+	return "", fmt.Errorf("vars file %s: unknown format (expected .yaml, .yml, .json or .env)", path)
+}
+
+// LoadVarsFile reads a YAML, JSON or dotenv file of variables. YAML files may use
+// the !env, !cmd and !file tags; dotenv values are always strings.
+func LoadVarsFile(path string) (map[string]VarValue, error) {
+	format, err := varsFileFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read vars file: %w", err)
+	}
+	if format == "dotenv" {
+		return parseDotenv(path, raw)
+	}
+
+	// JSON is read as YAML, which it is a subset of
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	vars := map[string]VarValue{}
+	if len(doc.Content) == 0 {
+		return vars, nil
+	}
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping of variables", path)
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, val := m.Content[i].Value, m.Content[i+1]
+		var v VarValue
+		if err := val.Decode(&v); err != nil {
+			return nil, fmt.Errorf("%s: variable %s: %w", path, key, err)
+		}
+		if v.Spec != nil {
+			if err := validateVarSpec(key, v.Spec); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		vars[key] = v
+	}
+	return vars, nil
+}
+
+// parseDotenv reads KEY=VALUE lines. Blank lines and # comments are skipped, an
+// "export " prefix is allowed, single-quoted values are taken verbatim and
+// double-quoted values support \n, \t, \" and \\ escapes. There is no ${VAR}
+// expansion.
+func parseDotenv(path string, raw []byte) (map[string]VarValue, error) {
+	vars := map[string]VarValue{}
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" || strings.ContainsAny(k, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		v = strings.TrimSpace(v)
+		switch {
+		case len(v) >= 2 && v[0] == '\'' && strings.HasSuffix(v, "'"):
+			v = v[1 : len(v)-1]
+		case len(v) >= 2 && v[0] == '"' && strings.HasSuffix(v, `"`):
+			v = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1])
+		default:
+			// Unquoted values end at an inline comment
+			if i := strings.Index(v, " #"); i >= 0 {
+				v = strings.TrimSpace(v[:i])
+			}
+		}
+		vars[k] = NewLiteralVar(v)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return vars, nil
+}
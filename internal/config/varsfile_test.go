@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name: "comments and blank lines",
+			src:  "# header\n\nA=1\n  # indented comment\nB=two # trailing comment\nC=a#b\n",
+			want: map[string]any{"A": "1", "B": "two", "C": "a#b"},
+		},
+		{
+			name: "export prefix",
+			src:  "export A=1\nexport  B = spaced \nexported=x\n",
+			want: map[string]any{"A": "1", "B": "spaced", "exported": "x"},
+		},
+		{
+			name: "single quotes are verbatim",
+			src:  `A='x # not a comment'` + "\n" + `B='a\nb'` + "\n" + `C=''` + "\n",
+			want: map[string]any{"A": "x # not a comment", "B": `a\nb`, "C": ""},
+		},
+		{
+			name: "double quotes unescape",
+			src:  `A="line1\nline2"` + "\n" + `B="tab\there"` + "\n" + `C="say \"hi\""` + "\n" + `D="back\\slash"` + "\n" + `E="# kept"` + "\n",
+			want: map[string]any{"A": "line1\nline2", "B": "tab\there", "C": `say "hi"`, "D": `back\slash`, "E": "# kept"},
+		},
+		{
+			name: "no expansion and empty values",
+			src:  "A=${HOME}\nB=\nC==x\n",
+			want: map[string]any{"A": "${HOME}", "B": "", "C": "=x"},
+		},
+		{
+			name: "unbalanced quote is kept",
+			src:  `A="open` + "\n",
+			want: map[string]any{"A": `"open`},
+		},
+		{name: "missing equals", src: "A=1\nB\n", wantErr: "vars.env:2: expected KEY=VALUE"},
+		{name: "empty key", src: "=x\n", wantErr: "vars.env:1: expected KEY=VALUE"},
+		{name: "space in key", src: "MY KEY=x\n", wantErr: "vars.env:1: expected KEY=VALUE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars, err := parseDotenv("vars.env", []byte(tt.src))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseDotenv() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDotenv() error = %v", err)
+			}
+			got := map[string]any{}
+			for k, v := range vars {
+				if v.Kind != VarLiteral {
+					t.Errorf("%s: kind %v, want literal", k, v.Kind)
+				}
+				got[k] = v.Value
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDotenv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVarsFileFormat(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "vars.yaml", want: "yaml"},
+		{path: "dir/vars.YML", want: "yaml"},
+		{path: "vars.json", want: "json"},
+		{path: ".env", want: "dotenv"},
+		{path: ".env.local", want: "dotenv"},
+		{path: "prod.env", want: "dotenv"},
+		{path: "vars.toml", wantErr: true},
+		{path: "env", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := varsFileFormat(tt.path)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("varsFileFormat(%q) = %q, %v; want %q, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadVarsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		src     string
+		want    map[string]VarValue
+		wantErr string
+	}{
+		{
+			name: "yaml with tags",
+			file: "vars.yaml",
+			src:  "A: 1\nB: !env HOME\nC: [x, y]\n",
+			want: map[string]VarValue{
+				"A": NewLiteralVar(int64(1)),
+				"B": NewEnvVar("HOME"),
+				"C": {Kind: VarList, List: []VarValue{NewLiteralVar("x"), NewLiteralVar("y")}},
+			},
+		},
+		{
+			name: "json",
+			file: "vars.json",
+			src:  `{"A": "1", "B": true}`,
+			want: map[string]VarValue{"A": NewLiteralVar("1"), "B": NewLiteralVar(true)},
+		},
+		{
+			name: "dotenv values stay strings",
+			file: ".env",
+			src:  "A=1\nB=true\n",
+			want: map[string]VarValue{"A": NewLiteralVar("1"), "B": NewLiteralVar("true")},
+		},
+		{name: "empty yaml", file: "vars.yml", src: "", want: map[string]VarValue{}},
+		{name: "yaml list", file: "vars.yaml", src: "- a\n", wantErr: "expected a mapping of variables"},
+		{name: "invalid declaration", file: "vars.yaml", src: "A: !var {type: float}\n", wantErr: "vars.yaml: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadVarsFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadVarsFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadVarsFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadVarsFile() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadVarsFileMissing(t *testing.T) {
+	_, err := LoadVarsFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "read vars file") {
+		t.Errorf("LoadVarsFile() error = %v, want read vars file error", err)
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// varTypes lists the values accepted by VarSpec.Type.
+var varTypes = []string{"string", "int", "bool", "list", "map"}
+
+// VarSpec declares what a variable must hold. It is written as a `!var` mapping
+// next to the variable's value:
+//
+//	GO_VERSION: !var
+//	  value: !env GO_VERSION
+//	  type: string
+//	  required: true
+//	  description: Go toolchain version
+type VarSpec struct {
+	Type        string    `yaml:"type,omitempty"`     // string, int, bool, list or map; empty accepts anything
+	Default     *VarValue `yaml:"default,omitempty"`  // used when the value is unset or empty
+	Required    bool      `yaml:"required,omitempty"` // fail when the value (after default) is empty
+	Enum        []any     `yaml:"enum,omitempty"`     // allowed values
+	Pattern     string    `yaml:"pattern,omitempty"`  // regular expression the value must match
+	Description string    `yaml:"description,omitempty"`
+}
+
+// varDecl is the YAML layout of a `!var` mapping.
+type varDecl struct {
+	Value   *VarValue `yaml:"value,omitempty"`
+	VarSpec `yaml:",inline"`
+}
+
+// IsSet reports whether v carries a value: a `!var` declaration may leave it to a
+// varsFile or a command-line override.
+func (v VarValue) IsSet() bool { return v.Kind != VarLiteral || v.Value != nil }
+
+func (v VarValue) marshalDecl() (any, error) {
+	decl := varDecl{VarSpec: *v.Spec}
+	if v.IsSet() {
+		val := v
+		val.Spec = nil
+		decl.Value = &val
+	}
+	var n yaml.Node
+	if err := n.Encode(decl); err != nil {
+		return nil, err
+	}
+	n.Tag = "!var"
+	return &n, nil
+}
+
+func (v *VarValue) unmarshalDecl(node *yaml.Node) error {
+	plain := *node
+	plain.Tag = "!!map"
+	var decl varDecl
+	if err := plain.Decode(&decl); err != nil {
+		return err
+	}
+	*v = VarValue{Kind: VarLiteral}
+	if decl.Value != nil {
+		if decl.Value.Spec != nil {
+			return fmt.Errorf("line %d: !var cannot be nested", node.Line)
+		}
+		*v = *decl.Value
+	}
+	v.Spec = &decl.VarSpec
+	return nil
+}
+
+func validateVarSpec(name string, s *VarSpec) error {
+	if s.Type != "" && !slices.Contains(varTypes, s.Type) {
+		return fmt.Errorf("variable %s: invalid type %q (expected string, int, bool, list or map)", name, s.Type)
+	}
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("variable %s: invalid pattern: %w", name, err)
+		}
+	}
+	if s.Default != nil && s.Default.Spec != nil {
+		return fmt.Errorf("variable %s: default cannot be a !var declaration", name)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Package filelock provides advisory, cross-process file locks used to serialize
+// access to the shared .duck cache.
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Lock is a held lock on a file. Release it with Unlock.
+type Lock struct {
+	f    *os.File
+	path string
+}
+
+// Acquire blocks until the exclusive lock on path is held, creating the file
+// (and its parent directory) if needed.
+func Acquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return acquire(path)
+}
+
+// Unlock releases the lock. It is safe to call on a nil Lock.
+func (l *Lock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	return l.release()
+}
@@ -0,0 +1,39 @@
+//go:build !unix
+
+package filelock
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Without flock, fall back to an exclusively created sidecar file. A lock left
+// behind by a crashed process is considered stale after staleAfter.
+const staleAfter = 10 * time.Minute
+
+func acquire(path string) (*Lock, error) {
+	held := path + ".held"
+	for {
+		f, err := os.OpenFile(held, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			return &Lock{f: f, path: held}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if fi, err := os.Stat(held); err == nil && time.Since(fi.ModTime()) > staleAfter {
+			_ = os.Remove(held)
+			continue
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (l *Lock) release() error {
+	err := l.f.Close()
+	if rerr := os.Remove(l.path); err == nil {
+		err = rerr
+	}
+	return err
+}
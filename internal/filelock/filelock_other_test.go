@@ -0,0 +1,70 @@
+//go:build !unix
+
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireTakesOverStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "t.lock")
+	held := path + ".held"
+	if err := os.WriteFile(held, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-staleAfter - time.Minute)
+	if err := os.Chtimes(held, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		lk, err := Acquire(path)
+		if err == nil {
+			err = lk.Unlock()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Acquire() over a stale lock error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire() did not take over a lock older than staleAfter")
+	}
+	if _, err := os.Stat(held); !os.IsNotExist(err) {
+		t.Errorf("Unlock() left %s behind: %v", held, err)
+	}
+}
+
+func TestAcquireWaitsForFreshLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "t.lock")
+	if err := os.WriteFile(path+".held", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		if lk, err := Acquire(path); err == nil {
+			lk.Unlock()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Acquire() took over a lock younger than staleAfter")
+	case <-time.After(200 * time.Millisecond):
+	}
+	// Removing the sidecar file releases the lock
+	if err := os.Remove(path + ".held"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire() still blocked after the lock was released")
+	}
+}
@@ -0,0 +1,64 @@
+package filelock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireCreatesParentDir(t *testing.T) {
+	lk, err := Acquire(filepath.Join(t.TempDir(), "a", "b", "t.lock"))
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if err := lk.Unlock(); err != nil {
+		t.Errorf("Unlock() error = %v", err)
+	}
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "t.lock")
+	first, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan *Lock)
+	go func() {
+		second, err := Acquire(path)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- second
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() returned while the lock was held")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if err := first.Unlock(); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	select {
+	case second := <-acquired:
+		if err := second.Unlock(); err != nil {
+			t.Errorf("Unlock() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire() still blocked after the lock was released")
+	}
+
+	// Released locks can be taken again
+	again, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+	again.Unlock()
+}
+
+func TestUnlockNil(t *testing.T) {
+	var lk *Lock
+	if err := lk.Unlock(); err != nil {
+		t.Errorf("Unlock() on nil = %v, want nil", err)
+	}
+}
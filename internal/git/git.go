@@ -2,36 +2,177 @@ package git
 
 import (
 	"fmt"
+	"net/url"
 	"os/exec"
-	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
 )
 
-// CloneInto clones/fetches repo@ref into cacheDir/repo and checks out the ref in the workdir.
-// Returns the workdir path with the working tree set to the requested ref (detached HEAD).
-func CloneInto(repo, ref, cacheDir string) (string, error) {
-	workdir := filepath.Join(cacheDir, "repo") // 1-repo MVP, improve later
+// CloneOptions tunes how a template repository is fetched.
+type CloneOptions struct {
+	// Shallow fetches only the requested commit (--depth 1); otherwise full history is fetched.
+	Shallow bool
+	// Submodules initializes and updates submodules recursively after checkout.
+	Submodules bool
+}
 
-	// Already cloned?
-	if _, err := exec.Command("test", "-d", filepath.Join(workdir, ".git")).CombinedOutput(); err == nil {
-		// Fetch the desired ref and checkout FETCH_HEAD (detached)
-		if out, err := exec.Command("git", "-C", workdir, "fetch", "--depth", "1", "origin", ref).CombinedOutput(); err != nil {
-			return "", fmt.Errorf("git fetch failed: %v: %s", err, string(out))
+// RepoHost extracts the hostname from a Git remote URL.
+// Supports URL forms (https://, ssh://, git://) and scp-like syntax (git@host:org/repo.git).
+// Returns an empty string for local paths.
+func RepoHost(repo string) string {
+	repo = strings.TrimSpace(repo)
+	if i := strings.Index(repo, "://"); i != -1 {
+		u, err := url.Parse(repo)
+		if err != nil {
+			return ""
 		}
-		if out, err := exec.Command("git", "-C", workdir, "checkout", "--force", "--detach", "FETCH_HEAD").CombinedOutput(); err != nil {
-			return "", fmt.Errorf("git checkout failed: %v: %s", err, string(out))
+		return u.Hostname()
+	}
+	// scp-like: [user@]host:path (a colon before any slash)
+	colon := strings.Index(repo, ":")
+	if colon == -1 {
+		return ""
+	}
+	if slash := strings.Index(repo, "/"); slash != -1 && slash < colon {
+		return ""
+	}
+	host := repo[:colon]
+	if at := strings.LastIndex(host, "@"); at != -1 {
+		host = host[at+1:]
+	}
+	return host
+}
+
+// IsCommitSHA reports whether ref is a full 40-character hex commit id.
+func IsCommitSHA(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	for _, c := range ref {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
 		}
-	} else {
-		// Fresh clone, then force checkout the ref (supports branch, tag, or commit)
-		if out, err := exec.Command("git", "clone", "--depth", "1", repo, workdir).CombinedOutput(); err != nil {
-			return "", fmt.Errorf("git clone failed: %v: %s", err, string(out))
+	}
+	return true
+}
+
+// ResolveRef asks the remote which commit ref points to (git ls-remote).
+// Branches, tags (peeled to their commit) and HEAD are supported; a full
+// commit SHA is returned as-is without touching the network.
+func ResolveRef(repo, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if IsCommitSHA(ref) {
+		return strings.ToLower(ref), nil
+	}
+	out, err := exec.Command("git", "ls-remote", repo, ref, ref+"^{}").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote failed: %v: %s", err, string(out))
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+	// Preference order: exact name, branch, peeled tag, tag
+	for _, name := range []string{ref, "refs/heads/" + ref, "refs/tags/" + ref + "^{}", "refs/tags/" + ref} {
+		if sha, ok := refs[name]; ok {
+			return sha, nil
 		}
-		// Ensure we have the ref and check it out detached
-		if out, err := exec.Command("git", "-C", workdir, "fetch", "--depth", "1", "origin", ref).CombinedOutput(); err != nil {
-			return "", fmt.Errorf("git fetch failed: %v: %s", err, string(out))
+	}
+	return "", fmt.Errorf("ref %q not found in %s", ref, repo)
+}
+
+// versionWildcard matches wildcard versions such as 2.x, v1.2.* or 3.X.
+var versionWildcard = regexp.MustCompile(`^v?(\d+|[xX*])(\.(\d+|[xX*])){0,2}$`)
+
+// IsVersionConstraint reports whether ref is a semver constraint (^2.3, ~2.3.0,
+// >=1.2 <2, 2.x, 1.0 - 1.4, ...) rather than a plain branch, tag or commit.
+func IsVersionConstraint(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return false
+	}
+	if strings.ContainsAny(ref[:1], "^~<>=!") || strings.Contains(ref, "||") || strings.Contains(ref, " - ") {
+		return true
+	}
+	return versionWildcard.MatchString(ref) && strings.ContainsAny(ref, "xX*")
+}
+
+// ListTags returns the remote's tags mapped to the commit they point to
+// (annotated tags are peeled).
+func ListTags(repo string) (map[string]string, error) {
+	out, err := exec.Command("git", "ls-remote", "--tags", repo).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-remote failed: %v: %s", err, string(out))
+	}
+	tags := map[string]string{}
+	peeled := map[string]bool{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "refs/tags/") {
+			continue
+		}
+		name := strings.TrimPrefix(fields[1], "refs/tags/")
+		if base, ok := strings.CutSuffix(name, "^{}"); ok {
+			tags[base], peeled[base] = fields[0], true
+		} else if !peeled[name] {
+			tags[name] = fields[0]
+		}
+	}
+	return tags, nil
+}
+
+// ResolveConstraint returns the highest remote tag satisfying the semver constraint,
+// together with its commit.
+func ResolveConstraint(repo, constraint string) (tag, commit string, err error) {
+	tags, err := ListTags(repo)
+	if err != nil {
+		return "", "", err
+	}
+	if tag, err = MatchConstraint(tags, constraint); err != nil {
+		return "", "", fmt.Errorf("%w in %s", err, repo)
+	}
+	return tag, tags[tag], nil
+}
+
+// MatchConstraint returns the highest of tags satisfying the semver constraint.
+// Tags that are not semantic versions are ignored; a leading "v" is allowed.
+func MatchConstraint(tags map[string]string, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+	if tag := highestTag(tags, c.Check); tag != "" {
+		return tag, nil
+	}
+	return "", fmt.Errorf("no tag satisfies version constraint %q", constraint)
+}
+
+// LatestVersion returns the highest stable (non pre-release) semver tag, or "".
+func LatestVersion(tags map[string]string) string {
+	return highestTag(tags, func(v *semver.Version) bool { return v.Prerelease() == "" })
+}
+
+// highestTag returns the tag with the highest version accepted by keep. Between
+// equal versions (v1.2.0 and 1.2.0) the lexically smaller name wins.
+func highestTag(tags map[string]string, keep func(*semver.Version) bool) string {
+	var best *semver.Version
+	var tag string
+	for name := range tags {
+		v, err := semver.NewVersion(name)
+		if err != nil || !keep(v) {
+			continue
 		}
-		if out, err := exec.Command("git", "-C", workdir, "checkout", "--force", "--detach", "FETCH_HEAD").CombinedOutput(); err != nil {
-			return "", fmt.Errorf("git checkout failed: %v: %s", err, string(out))
+		if best == nil || v.GreaterThan(best) || (v.Equal(best) && name < tag) {
+			best, tag = v, name
 		}
 	}
-	return workdir, nil
+	return tag
 }
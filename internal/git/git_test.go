@@ -0,0 +1,90 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsVersionConstraint(t *testing.T) {
+	for ref, want := range map[string]bool{
+		"^1.2":        true,
+		"~1.2.0":      true,
+		">=1.2 <2":    true,
+		"1.0 - 1.4":   true,
+		"^1 || ^2":    true,
+		"2.x":         true,
+		"v1.2.*":      true,
+		"v1.2.0":      false,
+		"main":        false,
+		"":            false,
+		"release-2.x": false,
+	} {
+		if got := IsVersionConstraint(ref); got != want {
+			t.Errorf("IsVersionConstraint(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func TestMatchConstraint(t *testing.T) {
+	tags := map[string]string{
+		"v1.0.0":        "a",
+		"v1.2.0":        "b",
+		"v1.2.3":        "c",
+		"v1.3.0":        "d",
+		"v2.0.0-beta.1": "e",
+		"v2.0.0":        "f",
+		"v2.1.0-rc.1":   "g",
+		"1.2.3":         "h",
+		"latest":        "i",
+	}
+	tests := []struct {
+		constraint string
+		want       string
+		wantErr    string
+	}{
+		{constraint: "^1.0", want: "v1.3.0"},
+		{constraint: "^1.2.1", want: "v1.3.0"},
+		{constraint: "~1.2.0", want: "1.2.3"}, // equal versions: the lexically smaller name wins
+		{constraint: "~1.0", want: "v1.0.0"},
+		{constraint: ">=1.2 <1.3", want: "1.2.3"},
+		{constraint: "1.0 - 1.2", want: "1.2.3"},
+		{constraint: "^1 || ^2", want: "v2.0.0"},
+		{constraint: "2.x", want: "v2.0.0"},
+		{constraint: "^2", want: "v2.0.0"}, // pre-releases only match constraints that name one
+		{constraint: ">=2.1.0-0", want: "v2.1.0-rc.1"},
+		{constraint: "~2.0.0-beta", want: "v2.0.0"},
+		{constraint: "^3", wantErr: `no tag satisfies version constraint "^3"`},
+		{constraint: "^0.1", wantErr: `no tag satisfies version constraint "^0.1"`},
+		{constraint: "^x.y", wantErr: `invalid version constraint "^x.y"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			got, err := MatchConstraint(tags, tt.constraint)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MatchConstraint() = %q, %v; want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("MatchConstraint() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	tests := []struct {
+		tags map[string]string
+		want string
+	}{
+		{tags: map[string]string{"v1.0.0": "a", "v1.1.0": "b", "v2.0.0-rc.1": "c"}, want: "v1.1.0"},
+		{tags: map[string]string{"v2.0.0-rc.1": "c", "main": "d"}, want: ""},
+		{tags: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := LatestVersion(tt.tags); got != tt.want {
+			t.Errorf("LatestVersion(%v) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
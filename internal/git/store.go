@@ -0,0 +1,253 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/CyberDuck79/duckfile/internal/archive"
+)
+
+// Store is a cache of bare repository mirrors keyed by normalized repo URL.
+// Each mirror lives in <Dir>/<hash>/mirror.git and commits are extracted once
+// into <Dir>/<hash>/trees/<commit> (<commit>+sm with submodules), so any number
+// of targets (or projects, when Dir is user-level) share one clone per repository.
+type Store struct {
+	Dir string
+	// Warnf receives warnings, such as symlinks left out of an extracted tree
+	// because they point outside it; nil discards them.
+	Warnf func(format string, args ...any)
+}
+
+// NormalizeURL canonicalizes cosmetic differences in a repo URL (case of scheme
+// and host, trailing slashes, ".git" suffix) so equivalent URLs share a mirror.
+func NormalizeURL(repo string) string {
+	repo = strings.TrimSpace(repo)
+	repo = strings.TrimRight(repo, "/")
+	repo = strings.TrimSuffix(repo, ".git")
+	if i := strings.Index(repo, "://"); i != -1 {
+		if u, err := url.Parse(repo); err == nil {
+			u.Scheme = strings.ToLower(u.Scheme)
+			u.Host = strings.ToLower(u.Host)
+			return u.String()
+		}
+		return repo
+	}
+	// scp-like [user@]host:path: lowercase the host part only
+	if host := RepoHost(repo); host != "" {
+		i := strings.Index(repo, host)
+		return repo[:i] + strings.ToLower(host) + repo[i+len(host):]
+	}
+	return repo
+}
+
+// repoDir returns the store directory for repo.
+func (s *Store) repoDir(repo string) string {
+	sum := sha256.Sum256([]byte(NormalizeURL(repo)))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:8]))
+}
+
+func (s *Store) mirror(repo string) string {
+	return filepath.Join(s.repoDir(repo), "mirror.git")
+}
+
+// LockPath returns the lock file guarding the mirror and trees of repo.
+func (s *Store) LockPath(repo string) string {
+	return filepath.Join(s.repoDir(repo), "lock")
+}
+
+// TreeDir returns where commit is extracted for repo, whether or not it exists yet.
+// Trees with submodules are kept apart (trees/<commit>+sm) from plain ones.
+func (s *Store) TreeDir(repo, commit string, submodules bool) string {
+	if submodules {
+		commit += "+sm"
+	}
+	return filepath.Join(s.repoDir(repo), "trees", commit)
+}
+
+// HasTree reports whether commit has already been extracted for repo, with or
+// without submodules.
+func (s *Store) HasTree(repo, commit string, submodules bool) bool {
+	fi, err := os.Stat(s.TreeDir(repo, commit, submodules))
+	return err == nil && fi.IsDir()
+}
+
+// HasCommit reports whether the mirror already contains commit.
+func (s *Store) HasCommit(repo, commit string) bool {
+	m := s.mirror(repo)
+	if _, err := os.Stat(m); err != nil {
+		return false
+	}
+	return exec.Command("git", "-C", m, "cat-file", "-e", commit+"^{commit}").Run() == nil
+}
+
+// IsShallow reports whether the mirror for repo holds truncated history.
+func (s *Store) IsShallow(repo string) bool {
+	_, err := os.Stat(filepath.Join(s.mirror(repo), "shallow"))
+	return err == nil
+}
+
+// Fetch fetches ref (branch, tag or commit) into the mirror, creating it if needed,
+// and returns the fetched commit SHA.
+func (s *Store) Fetch(repo, ref string, opts CloneOptions) (string, error) {
+	m := s.mirror(repo)
+	if _, err := os.Stat(filepath.Join(m, "HEAD")); err != nil {
+		if err := os.MkdirAll(filepath.Dir(m), 0o755); err != nil {
+			return "", err
+		}
+		if out, err := exec.Command("git", "init", "--bare", "--quiet", m).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git init failed: %v: %s", err, string(out))
+		}
+		remote := repo
+		if RepoHost(repo) == "" && !strings.Contains(repo, "://") && !filepath.IsAbs(repo) {
+			// git -C mirror would resolve a relative path from the mirror
+			if remote, err = filepath.Abs(repo); err != nil {
+				return "", err
+			}
+		}
+		if out, err := exec.Command("git", "-C", m, "remote", "add", "origin", remote).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git remote add failed: %v: %s", err, string(out))
+		}
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	fetch := []string{"-C", m, "fetch", "--quiet", "--no-tags"}
+	if opts.Shallow {
+		fetch = append(fetch, "--depth", "1")
+	} else if s.IsShallow(repo) {
+		// A previous shallow fetch must be completed to expose history
+		fetch = append(fetch, "--unshallow")
+	}
+	if out, err := exec.Command("git", append(fetch, "origin", ref)...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git fetch failed: %v: %s", err, string(out))
+	}
+	out, err := exec.Command("git", "-C", m, "rev-parse", "--verify", "FETCH_HEAD^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse FETCH_HEAD failed: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// FetchAll fetches every branch and tag with full history into the mirror.
+func (s *Store) FetchAll(repo string) error {
+	if _, err := s.Fetch(repo, "HEAD", CloneOptions{}); err != nil {
+		return err
+	}
+	m := s.mirror(repo)
+	out, err := exec.Command("git", "-C", m, "fetch", "--quiet", "origin",
+		"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git fetch failed: %v: %s", err, string(out))
+	}
+	return nil
+}
+
+// RevParse resolves a revision expression (e.g. "main~2", "v1.0^") against the
+// mirror, trying remote branches first.
+func (s *Store) RevParse(repo, rev string) (string, error) {
+	m := s.mirror(repo)
+	for _, cand := range []string{"origin/" + rev, rev} {
+		out, err := exec.Command("git", "-C", m, "rev-parse", "--verify", "--quiet", cand+"^{commit}").Output()
+		if err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", fmt.Errorf("revision %q not found in %s", rev, repo)
+}
+
+// Extract materializes commit from the mirror and returns its directory. Plain trees
+// are extracted with git archive; with submodules a detached worktree is created
+// and its submodules updated. Either is built in a temporary directory and renamed
+// into place, so a tree that exists is complete. Extracted trees are immutable and
+// reused.
+func (s *Store) Extract(repo, commit string, opts CloneOptions) (string, error) {
+	dst := s.TreeDir(repo, commit, opts.Submodules)
+	if s.HasTree(repo, commit, opts.Submodules) {
+		return dst, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), ".extract-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	m := s.mirror(repo)
+	if opts.Submodules {
+		err = worktreeInto(m, commit, tmp, opts.Shallow)
+	} else {
+		err = archiveInto(m, commit, tmp, s.warnf)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dst); err != nil && !s.HasTree(repo, commit, opts.Submodules) {
+		return "", err
+	}
+	if opts.Submodules {
+		// The worktree was registered at tmp; the tree does not need its git metadata
+		_ = exec.Command("git", "-C", m, "worktree", "prune").Run()
+	}
+	return dst, nil
+}
+
+// worktreeInto checks commit out into the empty directory dir as a detached
+// worktree and updates its submodules.
+func worktreeInto(mirror, commit, dir string, shallow bool) error {
+	// git -C mirror would resolve a relative dir from the mirror
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command("git", "-C", mirror, "worktree", "add", "--force", "--detach", dir, commit).CombinedOutput(); err != nil {
+		return fmt.Errorf("git worktree add failed: %v: %s", err, string(out))
+	}
+	sub := []string{"-C", dir, "submodule", "update", "--init", "--recursive", "--force"}
+	if shallow {
+		sub = append(sub, "--depth", "1")
+	}
+	if out, err := exec.Command("git", sub...).CombinedOutput(); err != nil {
+		_ = os.RemoveAll(dir)
+		_ = exec.Command("git", "-C", mirror, "worktree", "prune").Run()
+		return fmt.Errorf("git submodule update failed: %v: %s", err, string(out))
+	}
+	return nil
+}
+
+func (s *Store) warnf(format string, args ...any) {
+	if s.Warnf != nil {
+		s.Warnf(format, args...)
+	}
+}
+
+// archiveInto streams `git archive commit` into dir. Symlinks pointing outside the
+// tree are skipped with a warning: the repository is trusted, but its links must
+// not reach into the cache or the host.
+func archiveInto(mirror, commit, dir string, warnf func(format string, args ...any)) error {
+	cmd := exec.Command("git", "-C", mirror, "archive", "--format=tar", commit)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	extractErr := archive.ExtractTarSkipLinks(stdout, dir, func(name, link string) {
+		warnf("skipping symlink %s -> %s at %s: it points outside the repository", name, link, commit)
+	})
+	_, _ = io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive failed: %v: %s", err, stderr.String())
+	}
+	return extractErr
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestExtractSkipsSymlinksLeavingTheRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "--quiet")
+	if err := os.WriteFile(filepath.Join(repo, "a.tpl"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{"abs": "/etc/passwd", "up": "../outside", "in": "a.tpl"} {
+		if err := os.Symlink(target, filepath.Join(repo, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "--quiet", "-m", "init")
+
+	var warnings []string
+	s := &Store{Dir: t.TempDir(), Warnf: func(format string, args ...any) {
+		warnings = append(warnings, format)
+	}}
+	commit, err := s.Fetch(repo, "HEAD", CloneOptions{})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	dir, err := s.Extract(repo, commit, CloneOptions{})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("got %d warnings, want 2 (abs and up)", len(warnings))
+	}
+	for _, p := range []string{"abs", "up"} {
+		if _, err := os.Lstat(filepath.Join(dir, p)); err == nil {
+			t.Errorf("%s was extracted", p)
+		}
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "in")); err != nil || string(got) != "a" {
+		t.Errorf("in = %q, %v; want the link inside kept", got, err)
+	}
+}
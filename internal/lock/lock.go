@@ -0,0 +1,72 @@
+package lock
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the lockfile written next to duck.yaml.
+const FileName = "duck.lock"
+
+const header = "# Generated by `duck lock`. Do not edit by hand.\n"
+
+// Entry pins one target's template to an exact commit and content hash.
+type Entry struct {
+	Repo   string `yaml:"repo"`
+	Ref    string `yaml:"ref,omitempty"`
+	Tag    string `yaml:"tag,omitempty"` // tag a version constraint ref resolved to
+	Path   string `yaml:"path"`
+	Commit string `yaml:"commit"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// Matches reports whether the entry was recorded for the given template coordinates.
+func (e Entry) Matches(repo, ref, path string) bool {
+	return e.Repo == repo && e.Ref == ref && e.Path == path
+}
+
+// File is the content of duck.lock.
+type File struct {
+	Version int              `yaml:"version"`
+	Targets map[string]Entry `yaml:"targets"`
+}
+
+// Load reads a lockfile. The returned error wraps os.ErrNotExist when the file is missing.
+func Load(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if f.Version != 1 {
+		return nil, fmt.Errorf("%s: unsupported version %d", path, f.Version)
+	}
+	if f.Targets == nil {
+		f.Targets = map[string]Entry{}
+	}
+	return &f, nil
+}
+
+// Save writes the lockfile with a generated-file header.
+func (f *File) Save(path string) error {
+	if f.Version == 0 {
+		f.Version = 1
+	}
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
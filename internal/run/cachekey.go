@@ -0,0 +1,130 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+// cacheKeySchema versions the layout of cacheKeyInput. Bump it whenever a field is
+// added, removed or changes meaning so that every existing object is invalidated.
+const cacheKeySchema = 4
+
+// rendererVersion is bumped whenever duck changes how templates are rendered
+// (function map, options, output handling) without a change to cacheKeyInput,
+// including when a Go upgrade changes text/template output. The Go version itself
+// is left out of the key so that builds with different toolchains share objects.
+const rendererVersion = 1
+
+// cacheKeyInput lists every input that can change a rendered object. The cache key
+// is the hex SHA-256 of its JSON encoding; field order is fixed by the struct.
+type cacheKeyInput struct {
+	Schema     int       `json:"schema"`     // cacheKeySchema
+	Engine     string    `json:"engine"`     // renderer and sprig versions
+	Repo       string    `json:"repo"`       // template.repo as written
+	Local      string    `json:"local"`      // local template directory, if any
+	Ref        string    `json:"ref"`        // template.ref as written
+	Version    string    `json:"version"`    // tag a version constraint ref resolved to
+	Commit     string    `json:"commit"`     // commit SHA the template was read from (empty for local)
+	Path       string    `json:"path"`       // template.path
+	Template   string    `json:"template"`   // SHA-256 of the raw template bytes
+	Delims     [2]string `json:"delims"`     // effective left/right delimiters
+	MissingKey string    `json:"missingKey"` // text/template missingkey policy
+	Vars       []keyVar  `json:"vars"`       // resolved variables, sorted by name
+}
+
+type keyVar struct {
+	K string `json:"k"`
+	V any    `json:"v"`
+}
+
+// computeCacheKey builds a stable SHA-256 over every render-affecting input.
+func computeCacheKey(tpl config.Template, commit, version string, raw []byte, vars map[string]any) (string, error) {
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	pairs := make([]keyVar, 0, len(names))
+	for _, k := range names {
+		pairs = append(pairs, keyVar{K: k, V: vars[k]})
+	}
+	left, right := templateDelims(tpl)
+	in := cacheKeyInput{
+		Schema:     cacheKeySchema,
+		Engine:     engineVersion(),
+		Repo:       tpl.Repo,
+		Local:      tpl.Local,
+		Ref:        tpl.Ref,
+		Version:    version,
+		Commit:     commit,
+		Path:       tpl.Path,
+		Template:   sha256Hex(raw),
+		Delims:     [2]string{left, right},
+		MissingKey: missingKeyPolicy(tpl),
+		Vars:       pairs,
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(b), nil
+}
+
+// engineVersion identifies the rendering engine: duck's renderer version and the
+// sprig module version.
+func engineVersion() string {
+	sprigVersion := "unknown"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			if dep.Path == "github.com/Masterminds/sprig/v3" {
+				sprigVersion = dep.Version
+			}
+		}
+	}
+	return fmt.Sprintf("duck-render/%d sprig/%s", rendererVersion, sprigVersion)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// isLegacyKey reports whether key was produced by the SHA-1 based schema (v1).
+func isLegacyKey(key string) bool {
+	if len(key) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// migrateLegacyObjects removes objects keyed with the SHA-1 schema that no target's
+// symlink references anymore. Referenced ones are replaced (and removed) as their
+// target syncs.
+func migrateLegacyObjects(sess *session) {
+	lk, err := acquireObjectsLock()
+	if err != nil {
+		return
+	}
+	defer lk.Unlock()
+	dir := sess.settings.ObjectsDir()
+	referenced := referencedObjects(sess.links, dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() && isLegacyKey(e.Name()) && !referenced[e.Name()] {
+			sess.log.Debugf("removing legacy SHA-1 object %s", e.Name())
+			_ = os.RemoveAll(filepath.Join(dir, e.Name()))
+		}
+	}
+}
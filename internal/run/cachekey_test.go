@@ -0,0 +1,73 @@
+package run
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+func TestComputeCacheKey(t *testing.T) {
+	type input struct {
+		tpl  config.Template
+		raw  []byte
+		vars map[string]any
+	}
+	base := func() input {
+		return input{
+			tpl:  config.Template{Repo: "https://x/t.git", Ref: "v1", Path: "a.tpl"},
+			raw:  []byte("{{ .A }}"),
+			vars: map[string]any{"A": "1", "M": map[string]any{"x": int64(1), "y": []any{"a", "b"}}},
+		}
+	}
+	key := func(in input) string {
+		k, err := computeCacheKey(in.tpl, "abc", "", in.raw, in.vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	want := key(base())
+
+	tests := []struct {
+		name    string
+		edit    func(in *input)
+		changes bool
+	}{
+		{name: "delims", edit: func(in *input) { in.tpl.Delims = &config.Delims{Left: "[[", Right: "]]"} }, changes: true},
+		{name: "missingKey", edit: func(in *input) { in.tpl.AllowMissing = true }, changes: true},
+		{name: "template bytes", edit: func(in *input) { in.raw = []byte("{{ .A }}\n") }, changes: true},
+		{name: "variable value", edit: func(in *input) { in.vars["A"] = "2" }, changes: true},
+		{name: "variable type", edit: func(in *input) { in.vars["A"] = int64(1) }, changes: true},
+		{name: "nested value", edit: func(in *input) { in.vars["M"].(map[string]any)["x"] = int64(2) }, changes: true},
+		{name: "list order", edit: func(in *input) { in.vars["M"].(map[string]any)["y"] = []any{"b", "a"} }, changes: true},
+		{name: "default delims", edit: func(in *input) { in.tpl.Delims = &config.Delims{Left: "{{", Right: "}}"} }},
+		{name: "map insertion order", edit: func(in *input) {
+			m := map[string]any{}
+			m["y"] = []any{"a", "b"}
+			m["x"] = int64(1)
+			in.vars = map[string]any{}
+			in.vars["M"] = m
+			in.vars["A"] = "1"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := base()
+			tt.edit(&in)
+			// repeat: a key depending on map iteration order would eventually differ
+			for i := 0; i < 20; i++ {
+				if got := key(in); (got != want) != tt.changes {
+					t.Fatalf("key = %s, base key %s; want changed = %v", got, want, tt.changes)
+				}
+			}
+		})
+	}
+}
+
+func TestEngineVersionOmitsGoVersion(t *testing.T) {
+	if v := engineVersion(); strings.Contains(v, runtime.Version()) {
+		t.Errorf("engineVersion() = %q contains the Go version", v)
+	}
+}
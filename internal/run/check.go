@@ -0,0 +1,260 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+// CheckResult counts the problems found by Check.
+type CheckResult struct {
+	Missing int // variables templates use but targets do not define
+	Unused  int // target variables their template never uses
+}
+
+// Check parses the template of each selected target (all when targetName is empty)
+// and writes a report of the variables it uses that the target does not define,
+// with the lines using them, and of the target's variables it never uses. A
+// variable is used by {{ .NAME }}, {{ $.NAME }} or {{ index . "NAME" }}; nothing is
+// resolved or rendered, so no !cmd runs. Variables from the global varsFiles are
+// shared by every target and never reported as unused.
+func Check(cfg *config.DuckConf, targetName string, w io.Writer) (CheckResult, error) {
+	var result CheckResult
+	targets, err := collectTargets(cfg, targetName)
+	if err != nil {
+		return result, err
+	}
+	sess := newSession(cfg)
+	lf, err := loadLockFile()
+	if err != nil {
+		return result, err
+	}
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%-12s %-8s %-16s %-s\n", "TARGET", "PROBLEM", "VARIABLE", "WHERE")
+	for _, name := range names {
+		t := targets[name]
+		res, raw, err := fetchTemplate(sess, name, t, false, lockedEntry(sess.log, lf, name, t))
+		if err != nil {
+			return result, err
+		}
+		manifest, err := config.LoadManifest(res.Dir, t.Template.Path)
+		if err != nil {
+			return result, fmt.Errorf("target %q: %w", name, err)
+		}
+		refs, err := templateReferences(t, raw)
+		if err != nil {
+			return result, fmt.Errorf("target %q: %w", name, err)
+		}
+		defined, err := targetVariables(sess, t)
+		if err != nil {
+			return result, fmt.Errorf("target %q: %w", name, err)
+		}
+		if manifest != nil {
+			for k := range manifest.Variables {
+				defined[k] = config.VarValue{}
+			}
+		}
+		own, err := ownVariables(t)
+		if err != nil {
+			return result, fmt.Errorf("target %q: %w", name, err)
+		}
+
+		file := t.Template.Path
+		problems := 0
+		for _, k := range sortedKeys(refs.lines) {
+			if _, ok := defined[k]; ok {
+				continue
+			}
+			lines := make([]string, len(refs.lines[k]))
+			for i, l := range refs.lines[k] {
+				lines[i] = strconv.Itoa(l)
+			}
+			fmt.Fprintf(w, "%-12s %-8s %-16s %s:%s\n", name, "missing", k, file, strings.Join(lines, ","))
+			result.Missing++
+			problems++
+		}
+		if refs.whole {
+			fmt.Fprintf(w, "%-12s %-8s %-16s %s\n", name, "note", "-", "template uses . as a whole; unused variables not reported")
+		} else {
+			for _, k := range sortedKeys(own) {
+				if _, ok := refs.lines[k]; ok {
+					continue
+				}
+				fmt.Fprintf(w, "%-12s %-8s %-16s %s\n", name, "unused", k, own[k])
+				result.Unused++
+				problems++
+			}
+		}
+		if problems == 0 && !refs.whole {
+			fmt.Fprintf(w, "%-12s %s\n", name, "ok")
+		}
+	}
+	return result, nil
+}
+
+// ownVariables returns the variables a target defines itself, in its varsFiles or
+// inline, with where each is defined.
+func ownVariables(t config.Target) (map[string]string, error) {
+	own := map[string]string{}
+	for _, f := range t.VarsFiles {
+		vars, err := config.LoadVarsFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for k := range vars {
+			own[k] = f
+		}
+	}
+	for k := range t.Variables {
+		own[k] = "variables"
+	}
+	return own, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// templateRefs collects the top-level variables a template references.
+type templateRefs struct {
+	raw   []byte
+	lines map[string][]int // variable -> lines using it, ascending
+	whole bool             // the data is used as a whole, e.g. {{ toJson . }}
+}
+
+// templateReferences parses raw with the target's delimiters and the render
+// functions and walks every template it defines. Templates invoked with
+// {{ template "name" . }} are assumed to receive the root data.
+func templateReferences(t config.Target, raw []byte) (*templateRefs, error) {
+	left, right := templateDelims(t.Template)
+	tmpl, err := template.New(t.Template.Path).Funcs(templateFuncs()).Delims(left, right).Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	r := &templateRefs{raw: raw, lines: map[string][]int{}}
+	for _, tt := range tmpl.Templates() {
+		if tt.Tree != nil {
+			r.walk(tt.Tree.Root, true)
+		}
+	}
+	return r, nil
+}
+
+func (r *templateRefs) add(name string, pos parse.Pos) {
+	line := 1 + bytes.Count(r.raw[:min(int(pos), len(r.raw))], []byte("\n"))
+	if slices.Contains(r.lines[name], line) {
+		return
+	}
+	r.lines[name] = append(r.lines[name], line)
+	sort.Ints(r.lines[name])
+}
+
+// walk visits n; root reports whether dot is the template's data there, which it
+// stops being inside range and with.
+func (r *templateRefs) walk(n parse.Node, root bool) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			r.walk(c, root)
+		}
+	case *parse.ActionNode:
+		r.walk(n.Pipe, root)
+	case *parse.IfNode:
+		r.branch(&n.BranchNode, root, false)
+	case *parse.RangeNode:
+		r.branch(&n.BranchNode, root, true)
+	case *parse.WithNode:
+		r.branch(&n.BranchNode, root, true)
+	case *parse.TemplateNode:
+		// passing the data on is not a use: defined templates are walked as root
+		if n.Pipe != nil && len(n.Pipe.Decl) == 0 && len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 && r.isRoot(n.Pipe.Cmds[0].Args[0], root) {
+			return
+		}
+		r.walk(n.Pipe, root)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			r.walk(c, root)
+		}
+	case *parse.CommandNode:
+		// index . "NAME" names a variable; any other use of dot is whole-data use
+		if len(n.Args) >= 3 {
+			if id, ok := n.Args[0].(*parse.IdentifierNode); ok && id.Ident == "index" && r.isRoot(n.Args[1], root) {
+				if s, ok := n.Args[2].(*parse.StringNode); ok {
+					r.add(s.Text, s.Pos)
+					for _, a := range n.Args[3:] {
+						r.walk(a, root)
+					}
+					return
+				}
+			}
+		}
+		for _, a := range n.Args {
+			r.walk(a, root)
+		}
+	case *parse.FieldNode:
+		if root {
+			r.add(n.Ident[0], n.Pos)
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			if len(n.Ident) > 1 {
+				r.add(n.Ident[1], n.Pos)
+			} else {
+				r.whole = true
+			}
+		}
+	case *parse.ChainNode:
+		if r.isRoot(n.Node, root) && len(n.Field) > 0 {
+			r.add(n.Field[0], n.Pos)
+			return
+		}
+		r.walk(n.Node, root)
+	case *parse.DotNode:
+		if root {
+			r.whole = true
+		}
+	}
+}
+
+// branch visits if, range and with; rebinds reports whether the body runs with a
+// new dot. The pipeline and the else branch keep the current one.
+func (r *templateRefs) branch(b *parse.BranchNode, root, rebinds bool) {
+	r.walk(b.Pipe, root)
+	r.walk(b.List, root && !rebinds)
+	r.walk(b.ElseList, root)
+}
+
+// isRoot reports whether n evaluates to the template's data: dot at the root, or $.
+func (r *templateRefs) isRoot(n parse.Node, root bool) bool {
+	switch n := n.(type) {
+	case *parse.DotNode:
+		return root
+	case *parse.VariableNode:
+		return len(n.Ident) == 1 && n.Ident[0] == "$"
+	}
+	return false
+}
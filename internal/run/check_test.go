@@ -0,0 +1,110 @@
+package run
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+func TestTemplateReferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		tpl    string
+		delims *config.Delims
+		want   map[string][]int
+		whole  bool
+	}{
+		{
+			name: "fields at the root",
+			tpl:  "{{ .A }}\n{{ .B.C }}\n{{ .A }}",
+			want: map[string][]int{"A": {1, 3}, "B": {2}},
+		},
+		{
+			name: "range rebinds dot",
+			tpl:  "{{ range .Items }}\n{{ .Name }}\n{{ end }}",
+			want: map[string][]int{"Items": {1}},
+		},
+		{
+			name: "with rebinds dot but not its else",
+			tpl:  "{{ with .Opt }}{{ .Inner }}{{ else }}\n{{ .Fallback }}{{ end }}",
+			want: map[string][]int{"Opt": {1}, "Fallback": {2}},
+		},
+		{
+			name: "if keeps dot",
+			tpl:  "{{ if .On }}\n{{ .Then }}{{ else }}{{ .Else }}{{ end }}",
+			want: map[string][]int{"On": {1}, "Then": {2}, "Else": {2}},
+		},
+		{
+			name: "dollar reaches the root inside range",
+			tpl:  "{{ range .Items }}\n{{ $.Prefix }}{{ .Name }}{{ end }}",
+			want: map[string][]int{"Items": {1}, "Prefix": {2}},
+		},
+		{
+			name: "index names a variable",
+			tpl:  "{{ index . \"with-dash\" }}\n{{ range .L }}{{ index $ \"Y\" }}{{ index . \"Z\" }}{{ end }}",
+			want: map[string][]int{"with-dash": {1}, "L": {2}, "Y": {2}},
+		},
+		{
+			name:  "index with a dynamic key uses the whole data",
+			tpl:   "{{ index . .Key }}",
+			want:  map[string][]int{"Key": {1}},
+			whole: true,
+		},
+		{
+			name: "template passes the data on",
+			tpl:  "{{ define \"part\" }}\n{{ .Inner }}{{ end }}{{ template \"part\" . }}",
+			want: map[string][]int{"Inner": {2}},
+		},
+		{
+			name: "template with a field argument",
+			tpl:  "{{ template \"part\" .Sub }}{{ define \"part\" }}{{ end }}",
+			want: map[string][]int{"Sub": {1}},
+		},
+		{
+			name:  "dot as a whole",
+			tpl:   "{{ toJson . }}",
+			want:  map[string][]int{},
+			whole: true,
+		},
+		{
+			name:  "bare dollar",
+			tpl:   "{{ range .L }}{{ $ }}{{ end }}",
+			want:  map[string][]int{"L": {1}},
+			whole: true,
+		},
+		{
+			name:   "custom delimiters",
+			tpl:    "{{ .NotAnAction }}\n[[ .A ]]\n[[ if .B ]][[ end ]]",
+			delims: &config.Delims{Left: "[[", Right: "]]"},
+			want:   map[string][]int{"A": {2}, "B": {3}},
+		},
+		{
+			name: "variables and pipelines",
+			tpl:  "{{ $x := .A }}{{ $x }}\n{{ .B | upper }}{{ (.C).D }}",
+			want: map[string][]int{"A": {1}, "B": {2}, "C": {2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := config.Target{Template: config.Template{Path: "t.tpl", Delims: tt.delims}}
+			refs, err := templateReferences(target, []byte(tt.tpl))
+			if err != nil {
+				t.Fatalf("templateReferences() error = %v", err)
+			}
+			if !reflect.DeepEqual(refs.lines, tt.want) {
+				t.Errorf("lines = %v, want %v", refs.lines, tt.want)
+			}
+			if refs.whole != tt.whole {
+				t.Errorf("whole = %v, want %v", refs.whole, tt.whole)
+			}
+		})
+	}
+}
+
+func TestTemplateReferencesParseError(t *testing.T) {
+	target := config.Target{Template: config.Template{Path: "t.tpl"}}
+	if _, err := templateReferences(target, []byte("{{ .A ")); err == nil {
+		t.Error("templateReferences() on an unclosed action: want error")
+	}
+}
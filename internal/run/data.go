@@ -0,0 +1,79 @@
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeData parses the output of a structured-data variable (!json, !yaml-cmd, …)
+// into the shapes templates and the cache key expect: map[string]any, []any and
+// scalars, with integral numbers as int64.
+func decodeData(format string, b []byte) (any, error) {
+	var v any
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if dec.More() {
+			return nil, fmt.Errorf("unexpected data after the JSON value")
+		}
+	case "yaml":
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+	case "toml":
+		var m map[string]any
+		if err := toml.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		v = m
+	default:
+		return nil, fmt.Errorf("unknown data format %q", format)
+	}
+	return normalizeData(v), nil
+}
+
+// normalizeData converts JSON numbers and YAML maps with non-string keys so the
+// value encodes to JSON and behaves alike whatever format it came from.
+func normalizeData(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, c := range t {
+			t[k] = normalizeData(c)
+		}
+		return t
+	case map[any]any:
+		out := make(map[string]any, len(t))
+		for k, c := range t {
+			out[fmt.Sprint(k)] = normalizeData(c)
+		}
+		return out
+	case []any:
+		for i, c := range t {
+			t[i] = normalizeData(c)
+		}
+		return t
+	case []map[string]any: // TOML arrays of tables
+		out := make([]any, len(t))
+		for i, c := range t {
+			out[i] = normalizeData(c)
+		}
+		return out
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case int:
+		return int64(t)
+	}
+	return v
+}
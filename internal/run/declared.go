@@ -0,0 +1,173 @@
+package run
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+// checkDeclared applies a `!var` declaration to the resolved value of variable k
+// (nil when unset): an empty value takes the default, a required one must then be
+// non-empty, and the value is converted to the declared type (so "8080" from !env
+// becomes an int) before the enum and pattern checks.
+func checkDeclared(k string, spec config.VarSpec, val any) (any, error) {
+	if isEmptyValue(val) && spec.Default != nil {
+		def, err := ResolveVariable(k, *spec.Default)
+		if err != nil {
+			return nil, err
+		}
+		val = def
+	}
+	if isEmptyValue(val) {
+		if spec.Required {
+			if spec.Description != "" {
+				return nil, fmt.Errorf("%s is required (%s)", k, spec.Description)
+			}
+			return nil, fmt.Errorf("%s is required", k)
+		}
+		return zeroValue(spec.Type), nil
+	}
+	val, err := convertValue(spec.Type, val)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", k, err)
+	}
+	if len(spec.Enum) > 0 {
+		found := false
+		for _, e := range spec.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(val) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed := make([]string, len(spec.Enum))
+			for i, e := range spec.Enum {
+				allowed[i] = fmt.Sprint(e)
+			}
+			return nil, fmt.Errorf("%s: %v is not one of %s", k, val, strings.Join(allowed, ", "))
+		}
+	}
+	if spec.Pattern != "" {
+		re, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern: %w", k, err)
+		}
+		if s := fmt.Sprint(val); !re.MatchString(s) {
+			return nil, fmt.Errorf("%s: %q does not match %s", k, s, spec.Pattern)
+		}
+	}
+	return val, nil
+}
+
+func isEmptyValue(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case []any:
+		return len(t) == 0
+	case map[string]any:
+		return len(t) == 0
+	}
+	return false
+}
+
+// zeroValue is the value of an optional variable left unset: the zero value of its
+// type, or an empty string when it has none.
+func zeroValue(typ string) any {
+	switch typ {
+	case "string":
+		return ""
+	case "int":
+		return int64(0)
+	case "bool":
+		return false
+	case "list":
+		return []any{}
+	case "map":
+		return map[string]any{}
+	}
+	return ""
+}
+
+// convertValue checks val against a declared type, converting strings (as read
+// from the environment, files and commands) to ints and bools.
+func convertValue(typ string, val any) (any, error) {
+	switch typ {
+	case "string":
+		switch t := val.(type) {
+		case map[string]any, []any:
+			return nil, fmt.Errorf("expected a string, got a %s", typeName(t))
+		case string:
+			return t, nil
+		default:
+			return fmt.Sprint(t), nil
+		}
+	case "int":
+		switch t := val.(type) {
+		case int64:
+			return t, nil
+		case float64:
+			if t == math.Trunc(t) {
+				return int64(t), nil
+			}
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64); err == nil {
+				return i, nil
+			}
+		}
+		return nil, fmt.Errorf("expected an int, got %s", describeValue(val))
+	case "bool":
+		switch t := val.(type) {
+		case bool:
+			return t, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(t)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("expected a bool, got %s", describeValue(val))
+	case "list":
+		if _, ok := val.([]any); !ok {
+			return nil, fmt.Errorf("expected a list, got %s", describeValue(val))
+		}
+	case "map":
+		if _, ok := val.(map[string]any); !ok {
+			return nil, fmt.Errorf("expected a map, got %s", describeValue(val))
+		}
+	}
+	return val, nil
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "map"
+	case []any:
+		return "list"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func describeValue(v any) string {
+	switch t := v.(type) {
+	case map[string]any, []any:
+		return "a " + typeName(t)
+	case string:
+		return strconv.Quote(t)
+	}
+	return fmt.Sprintf("%v (%s)", v, typeName(v))
+}
@@ -0,0 +1,116 @@
+package run
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		typ     string
+		val     any
+		want    any
+		wantErr string
+	}{
+		{typ: "int", val: "8080", want: int64(8080)},
+		{typ: "int", val: " 42\n", want: int64(42)},
+		{typ: "int", val: int64(7), want: int64(7)},
+		{typ: "int", val: float64(3), want: int64(3)},
+		{typ: "int", val: 1.5, wantErr: "expected an int, got 1.5 (number)"},
+		{typ: "int", val: "abc", wantErr: `expected an int, got "abc"`},
+		{typ: "bool", val: "true", want: true},
+		{typ: "bool", val: "0", want: false},
+		{typ: "bool", val: "yes", wantErr: `expected a bool, got "yes"`},
+		{typ: "string", val: int64(1), want: "1"},
+		{typ: "string", val: []any{"a"}, wantErr: "expected a string, got a list"},
+		{typ: "list", val: []any{"a"}, want: []any{"a"}},
+		{typ: "list", val: "a", wantErr: `expected a list, got "a"`},
+		{typ: "map", val: map[string]any{}, want: map[string]any{}},
+		{typ: "", val: "anything", want: "anything"},
+	}
+	for _, tt := range tests {
+		got, err := convertValue(tt.typ, tt.val)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("convertValue(%q, %#v) error = %v, want %q", tt.typ, tt.val, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convertValue(%q, %#v) = %#v, %v; want %#v", tt.typ, tt.val, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckDeclared(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string // YAML of the !var mapping, without value
+		val     any
+		want    any
+		wantErr string
+	}{
+		{name: "env string to int", spec: "type: int", val: "8080", want: int64(8080)},
+		{name: "env string to bool", spec: "type: bool", val: "true", want: true},
+		{name: "default on empty", spec: "type: int\ndefault: 8080", val: "", want: int64(8080)},
+		{name: "default on unset", spec: "default: dev", val: nil, want: "dev"},
+		{name: "value wins over default", spec: "default: dev", val: "prod", want: "prod"},
+		{
+			name:    "required with description",
+			spec:    "type: string\nrequired: true\ndescription: Go toolchain version",
+			val:     "",
+			wantErr: "GO_VERSION is required (Go toolchain version)",
+		},
+		{name: "required without description", spec: "required: true", val: nil, wantErr: "GO_VERSION is required"},
+		{name: "optional typed unset", spec: "type: int", val: nil, want: int64(0)},
+		{name: "optional untyped unset", spec: "description: x", val: nil, want: ""},
+		{name: "int enum from env", spec: "type: int\nenum: [3, 4]", val: "3", want: int64(3)},
+		{name: "int enum from literal", spec: "type: int\nenum: [3, 4]", val: int64(4), want: int64(4)},
+		{name: "int enum rejects", spec: "type: int\nenum: [3, 4]", val: "5", wantErr: "GO_VERSION: 5 is not one of 3, 4"},
+		{name: "string enum", spec: "enum: [dev, prod]", val: "qa", wantErr: "GO_VERSION: qa is not one of dev, prod"},
+		{name: "pattern", spec: `pattern: '^1\.\d+$'`, val: "1.22", want: "1.22"},
+		{name: "pattern rejects", spec: `pattern: '^1\.\d+$'`, val: "2", wantErr: `GO_VERSION: "2" does not match ^1\.\d+$`},
+		{name: "conversion error names the variable", spec: "type: int", val: "x", wantErr: `GO_VERSION: expected an int, got "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v config.VarValue
+			if err := yaml.Unmarshal([]byte("!var\n"+tt.spec), &v); err != nil {
+				t.Fatalf("parse spec: %v", err)
+			}
+			got, err := checkDeclared("GO_VERSION", *v.Spec, tt.val)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("checkDeclared() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("checkDeclared() = %#v, %v; want %#v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveDeclaredFromEnv(t *testing.T) {
+	t.Setenv("DUCK_TEST_PORT", "9090")
+	var v config.VarValue
+	if err := yaml.Unmarshal([]byte("!var\nvalue: !env DUCK_TEST_PORT\ntype: int\nenum: [8080, 9090]"), &v); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ResolveVariable("PORT", v)
+	if err != nil || got != int64(9090) {
+		t.Fatalf("ResolveVariable() = %#v, %v; want int64(9090)", got, err)
+	}
+
+	// An empty environment variable leaves the optional int at its zero value
+	t.Setenv("DUCK_TEST_PORT", "")
+	got, err = ResolveVariable("PORT", v)
+	if err != nil || got != int64(0) {
+		t.Fatalf("ResolveVariable() with empty env = %#v, %v; want int64(0)", got, err)
+	}
+}
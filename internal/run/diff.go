@@ -0,0 +1,306 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/lock"
+)
+
+// Diff renders the would-be object of each selected target (all when targetName is
+// empty) and writes a unified diff against the file currently at the target's
+// rendered path (the object behind its symlink, or a committed file). Nothing is
+// written to the cache. It reports whether any target would change. A failing
+// target is logged and does not stop the others; the error reports how many failed.
+func Diff(cfg *config.DuckConf, targetName string, w io.Writer) (bool, error) {
+	targets, err := collectTargets(cfg, targetName)
+	if err != nil {
+		return false, err
+	}
+	sess := newSession(cfg)
+	lf, err := loadLockFile()
+	if err != nil {
+		return false, err
+	}
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := false
+	var failed []error
+	for _, name := range names {
+		c, err := diffTarget(sess, lf, name, targets[name], w)
+		if err != nil {
+			err = targetError(name, err)
+			if len(names) > 1 {
+				sess.log.Errorf("%v", err)
+			}
+			failed = append(failed, err)
+			continue
+		}
+		changed = changed || c
+	}
+	switch {
+	case len(failed) == 0:
+		return changed, nil
+	case len(names) == 1:
+		return changed, failed[0]
+	default:
+		return changed, fmt.Errorf("%d of %d targets failed to diff", len(failed), len(names))
+	}
+}
+
+// diffTarget writes the diff of one target and reports whether it would change.
+func diffTarget(sess *session, lf *lock.File, name string, t config.Target, w io.Writer) (bool, error) {
+	res, raw, err := fetchTemplate(sess, name, t, false, lockedEntry(sess.log, lf, name, t))
+	if err != nil {
+		return false, err
+	}
+	manifest, err := config.LoadManifest(res.Dir, t.Template.Path)
+	if err != nil {
+		return false, err
+	}
+	vars, err := resolveTargetVariables(sess, name, t, manifest)
+	if err != nil {
+		return false, err
+	}
+	rendered, err := executeTemplate(objectBase(t), raw, t, vars)
+	if err != nil {
+		return false, err
+	}
+
+	linkPath := targetLinkPath(name, t)
+	current, err := os.ReadFile(linkPath)
+	oldLabel := "a/" + linkPath
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+		oldLabel = "/dev/null"
+	} else if key := detectKeyFromSymlink(linkPath, sess.settings.ObjectsDir()); key != "" {
+		oldLabel += " (object " + shortKey(key) + ")"
+	}
+	if bytes.Equal(current, rendered) {
+		return false, nil
+	}
+	fmt.Fprintf(w, "# target %s\n", name)
+	writeUnifiedDiff(w, oldLabel, "b/"+linkPath+" (rendered)", splitLines(string(current)), splitLines(string(rendered)), 3)
+	return true, nil
+}
+
+// targetError prefixes err with the target name unless it already names it.
+func targetError(name string, err error) error {
+	if strings.HasPrefix(err.Error(), fmt.Sprintf("target %q: ", name)) {
+		return err
+	}
+	return fmt.Errorf("target %q: %w", name, err)
+}
+
+func shortKey(key string) string {
+	if len(key) > 12 {
+		return key[:12]
+	}
+	return key
+}
+
+// splitLines splits s into lines keeping their terminators, so a missing final
+// newline is visible in the diff.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is one line of an edit script: ' ' keep, '-' delete, '+' insert.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// maxDiffEdits bounds the differences editScript looks for a minimal script
+// through; its memory grows with their square.
+const maxDiffEdits = 2000
+
+// editScript computes a line-based edit script from a to b: the common prefix and
+// suffix are kept and the middle is diffed with myers.
+func editScript(a, b []string) []diffOp {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ops = append(ops, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+// myers returns a shortest edit script from a to b with Myers' O(ND) algorithm.
+// Beyond maxDiffEdits differences it gives up on minimality and replaces a by b.
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+	// v[off+k] = furthest x reached on diagonal k = x-y; trace[d] is v[-d..d] after round d
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1] // down: insert b[y]
+			} else {
+				x = v[off+k-1] + 1 // right: delete a[x]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+				return backtrack(a, b, trace)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+	}
+	ops := make([]diffOp, 0, n+m)
+	for _, l := range a {
+		ops = append(ops, diffOp{'-', l})
+	}
+	for _, l := range b {
+		ops = append(ops, diffOp{'+', l})
+	}
+	return ops
+}
+
+// backtrack walks the trace of myers back from the end of a and b.
+func backtrack(a, b []string, trace [][]int) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prev := trace[d-1] // covers diagonals -(d-1)..d-1
+			at := func(k int) int { return prev[k+d-1] }
+			prevK := k - 1
+			if k == -d || (k != d && at(k-1) < at(k+1)) {
+				prevK = k + 1
+			}
+			prevX = at(prevK)
+			prevY = prevX - prevK
+		}
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[y-1]})
+			} else {
+				ops = append(ops, diffOp{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// writeUnifiedDiff writes a unified diff of a and b with ctx lines of context.
+func writeUnifiedDiff(w io.Writer, oldLabel, newLabel string, a, b []string, ctx int) {
+	ops := editScript(a, b)
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldLabel, newLabel)
+
+	// aLine/bLine[k] = 1-based line numbers before op k
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+	aLine[0], bLine[0] = 1, 1
+	for k, op := range ops {
+		aLine[k+1], bLine[k+1] = aLine[k], bLine[k]
+		if op.kind != '+' {
+			aLine[k+1]++
+		}
+		if op.kind != '-' {
+			bLine[k+1]++
+		}
+	}
+
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		// Grow the hunk while changes are within 2*ctx lines of each other
+		start := max(0, k-ctx)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*ctx {
+				end = min(len(ops), end+ctx)
+				break
+			}
+			end = next
+		}
+		var aCount, bCount int
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(aLine[start], aCount), hunkRange(bLine[start], bCount))
+		for _, op := range ops[start:end] {
+			line := op.line
+			fmt.Fprintf(w, "%c%s", op.kind, line)
+			if !strings.HasSuffix(line, "\n") {
+				fmt.Fprint(w, "\n\\ No newline at end of file\n")
+			}
+		}
+		k = end
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,90 @@
+package run
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// lcsLen is the reference the edit scripts are checked against.
+func lcsLen(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		cur := make([]int, len(b)+1)
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				cur[j] = prev[j+1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j+1])
+			}
+		}
+		prev = cur
+	}
+	return prev[0]
+}
+
+// applyScript returns the old and new sides of an edit script.
+func applyScript(ops []diffOp) (old, new []string) {
+	for _, op := range ops {
+		if op.kind != '+' {
+			old = append(old, op.line)
+		}
+		if op.kind != '-' {
+			new = append(new, op.line)
+		}
+	}
+	return old, new
+}
+
+func TestEditScriptIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	gen := func() []string {
+		l := make([]string, rng.Intn(12))
+		for i := range l {
+			l[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return l
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := gen(), gen()
+		ops := editScript(a, b)
+		old, new := applyScript(ops)
+		if strings.Join(old, "") != strings.Join(a, "") || strings.Join(new, "") != strings.Join(b, "") {
+			t.Fatalf("editScript(%q, %q) = %v does not turn a into b", a, b, ops)
+		}
+		keep := 0
+		for _, op := range ops {
+			if op.kind == ' ' {
+				keep++
+			}
+		}
+		if want := lcsLen(a, b); keep != want {
+			t.Fatalf("editScript(%q, %q) keeps %d lines, want %d", a, b, keep, want)
+		}
+	}
+}
+
+func TestEditScriptLargeInputs(t *testing.T) {
+	a := make([]string, 50000)
+	for i := range a {
+		a[i] = fmt.Sprintf("line %d\n", i)
+	}
+	b := append([]string(nil), a...)
+	b[100], b[25000] = "changed\n", "changed too\n"
+	ops := editScript(a, b)
+	if n := len(ops); n != 50002 {
+		t.Fatalf("sparse change: got %d ops, want 50002", n)
+	}
+
+	// Entirely different files exceed maxDiffEdits and are replaced wholesale
+	c := make([]string, len(a))
+	for i := range c {
+		c[i] = fmt.Sprintf("other %d\n", i)
+	}
+	ops = editScript(a, c)
+	old, new := applyScript(ops)
+	if len(old) != len(a) || len(new) != len(c) || old[0] != a[0] || new[0] != c[0] {
+		t.Fatalf("fallback script does not turn a into c")
+	}
+}
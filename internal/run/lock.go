@@ -0,0 +1,179 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/lock"
+	"github.com/CyberDuck79/duckfile/internal/source"
+)
+
+// Lock resolves every target's template to a revision (commit SHA or digest) and content hash and writes duck.lock.
+// Existing entries that still match duck.yaml are kept unless update is set; when
+// targetName is non-empty, update only applies to that target.
+func Lock(cfg *config.DuckConf, targetName string, update bool) error {
+	if targetName != "" {
+		if _, err := collectTargets(cfg, targetName); err != nil {
+			return err
+		}
+	}
+	targets, err := collectTargets(cfg, "")
+	if err != nil {
+		return err
+	}
+	sess := newSession(cfg)
+	old, err := loadLockFile()
+	if err != nil {
+		return err
+	}
+	lf := &lock.File{Version: 1, Targets: map[string]lock.Entry{}}
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := targets[name]
+		if isLocal(t) {
+			continue // local templates are edited in place and never pinned
+		}
+		refresh := update && (targetName == "" || targetName == name)
+		if e, ok := lockEntry(old, name); ok && !refresh && e.Matches(t.Template.Repo, t.Template.Ref, t.Template.Path) {
+			lf.Targets[name] = e
+			continue
+		}
+		e, err := lockTarget(sess, name, t)
+		if err != nil {
+			return err
+		}
+		if prev, ok := lockEntry(old, name); !ok || prev.Commit != e.Commit {
+			if e.Tag != "" {
+				sess.log.Infof("locked %s to %s (%s)", name, e.Tag, e.Commit)
+			} else {
+				sess.log.Infof("locked %s to %s", name, e.Commit)
+			}
+		}
+		lf.Targets[name] = e
+	}
+	return lf.Save(lock.FileName)
+}
+
+func lockTarget(sess *session, name string, t config.Target) (lock.Entry, error) {
+	res, err := fetchSource(sess, t.Template, true, "")
+	if err != nil {
+		return lock.Entry{}, err
+	}
+	raw, err := os.ReadFile(filepath.Join(res.Dir, t.Template.Path))
+	if err != nil {
+		return lock.Entry{}, err
+	}
+	if err := verifyChecksum(t.Template, raw); err != nil {
+		return lock.Entry{}, fmt.Errorf("target %q: %w", name, err)
+	}
+	return lock.Entry{
+		Repo:   t.Template.Repo,
+		Ref:    t.Template.Ref,
+		Tag:    res.Version,
+		Path:   t.Template.Path,
+		Commit: res.Revision,
+		SHA256: sha256Hex(raw),
+	}, nil
+}
+
+// ResolvedVersion returns the tag a target's version constraint ref resolves to:
+// the one pinned in duck.lock, else the last resolution remembered by sync. It does
+// not touch the network and returns "" when the ref is not a constraint or was
+// never resolved.
+func ResolvedVersion(name string, t config.Target) string {
+	lf, _ := loadLockFile()
+	if e, ok := lockEntry(lf, name); ok && e.Tag != "" && e.Matches(t.Template.Repo, t.Template.Ref, t.Template.Path) {
+		return e.Tag
+	}
+	return source.ResolvedVersion(t.Template, source.Options{RefsFile: refsFile})
+}
+
+func isLocal(t config.Target) bool {
+	_, ok := t.Template.LocalDir()
+	return ok
+}
+
+// loadLockFile returns the project's lockfile, or nil if there is none.
+func loadLockFile() (*lock.File, error) {
+	lf, err := lock.Load(lock.FileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return lf, err
+}
+
+// unlockTarget drops a target's entry from duck.lock, if there is one.
+func unlockTarget(name string) error {
+	lf, err := loadLockFile()
+	if err != nil {
+		return err
+	}
+	if _, ok := lockEntry(lf, name); !ok {
+		return nil
+	}
+	delete(lf.Targets, name)
+	return lf.Save(lock.FileName)
+}
+
+// lockEntry looks up a target in a possibly nil lockfile.
+func lockEntry(lf *lock.File, name string) (lock.Entry, bool) {
+	if lf == nil {
+		return lock.Entry{}, false
+	}
+	e, ok := lf.Targets[name]
+	return e, ok
+}
+
+// lockedEntry returns the pin for a target, or nil when the target is not locked.
+// A stale entry (template coordinates changed in duck.yaml) is ignored with a warning.
+func lockedEntry(log *logger, lf *lock.File, name string, t config.Target) *lock.Entry {
+	e, ok := lockEntry(lf, name)
+	if !ok {
+		return nil
+	}
+	if !e.Matches(t.Template.Repo, t.Template.Ref, t.Template.Path) {
+		log.Warnf("%s is stale for target %s; run 'duck lock' to update it", lock.FileName, name)
+		return nil
+	}
+	return &e
+}
+
+// checkFrozen fails unless duck.lock exists and matches duck.yaml for the selected targets.
+func checkFrozen(cfg *config.DuckConf, lf *lock.File, targetName string) error {
+	if lf == nil {
+		return fmt.Errorf("%s not found; run 'duck lock' first", lock.FileName)
+	}
+	targets, err := collectTargets(cfg, targetName)
+	if err != nil {
+		return err
+	}
+	for name, t := range targets {
+		if isLocal(t) {
+			continue
+		}
+		e, ok := lf.Targets[name]
+		if !ok {
+			return fmt.Errorf("%s has no entry for target %q; run 'duck lock'", lock.FileName, name)
+		}
+		if !e.Matches(t.Template.Repo, t.Template.Ref, t.Template.Path) {
+			return fmt.Errorf("%s is stale for target %q (duck.yaml changed); run 'duck lock --update %s'", lock.FileName, name, name)
+		}
+	}
+	if targetName == "" {
+		for name := range lf.Targets {
+			if t, ok := targets[name]; !ok || isLocal(t) {
+				return fmt.Errorf("%s pins unknown target %q; run 'duck lock'", lock.FileName, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package run
+
+import (
+	"path/filepath"
+
+	"github.com/CyberDuck79/duckfile/internal/filelock"
+)
+
+// locksDir holds per-target lock files serializing concurrent duck invocations.
+var locksDir = filepath.Join(".duck", "locks")
+
+// acquireTargetLock serializes rendering and linking of one target across processes.
+func acquireTargetLock(name string) (*filelock.Lock, error) {
+	return filelock.Acquire(filepath.Join(locksDir, name+".lock"))
+}
+
+// objectsLockFile guards the objects dir; it lives outside locksDir so that no
+// target name can share it.
+var objectsLockFile = filepath.Join(".duck", "objects.lock")
+
+// acquireObjectsLock serializes writing, linking and removing rendered objects,
+// which targets with the same cache key share. Take it after the target lock.
+func acquireObjectsLock() (*filelock.Lock, error) {
+	return filelock.Acquire(objectsLockFile)
+}
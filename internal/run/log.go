@@ -0,0 +1,50 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Log levels, ordered by verbosity.
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// logger is a minimal leveled logger writing to stderr, driven by settings.logLevel.
+// It is safe for concurrent use.
+type logger struct {
+	level int
+	mu    sync.Mutex
+}
+
+func newLogger(level string) *logger {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return &logger{level: levelDebug}
+	case "warn":
+		return &logger{level: levelWarn}
+	case "error":
+		return &logger{level: levelError}
+	default:
+		return &logger{level: levelInfo}
+	}
+}
+
+func (l *logger) logf(level int, prefix, format string, args ...any) {
+	if level < l.level {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "duck: "+prefix+format+"\n", args...)
+}
+
+func (l *logger) Debugf(format string, args ...any) { l.logf(levelDebug, "debug: ", format, args...) }
+func (l *logger) Infof(format string, args ...any)  { l.logf(levelInfo, "", format, args...) }
+func (l *logger) Warnf(format string, args ...any)  { l.logf(levelWarn, "warn: ", format, args...) }
+func (l *logger) Errorf(format string, args ...any) { l.logf(levelError, "error: ", format, args...) }
@@ -0,0 +1,51 @@
+package run
+
+import (
+	"fmt"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+// applyManifest checks the resolved variables of target name against the template
+// manifest m, if any. Declared variables go through checkDeclared, so manifest
+// defaults fill in what the target leaves unset; variables the target defines, inline
+// or in its varsFiles, that the manifest does not declare are reported as warnings.
+func applyManifest(sess *session, name string, t config.Target, m *config.Manifest, vars map[string]any) error {
+	if m == nil {
+		return nil
+	}
+	for _, k := range sortedKeys(m.Variables) {
+		val, err := checkDeclared(k, m.Variables[k].Spec(), vars[k])
+		if err != nil {
+			return fmt.Errorf("template manifest %s: %w", m.Path, err)
+		}
+		vars[k] = val
+	}
+	// Same set as duck check reports unused: the target's inline variables and
+	// varsFiles, not the global varsFiles every target shares
+	own, err := ownVariables(t)
+	if err != nil {
+		return err
+	}
+	for _, k := range sortedKeys(own) {
+		if _, ok := m.Variables[k]; !ok {
+			sess.log.Warnf("target %s: variable %s (%s) is not declared in template manifest %s", name, k, own[k], m.Path)
+		}
+	}
+	return nil
+}
+
+// TemplateManifest fetches a target's template source (at its duck.lock pin when
+// there is one) and returns the template's manifest, or nil when it has none.
+func TemplateManifest(cfg *config.DuckConf, name string, t config.Target) (*config.Manifest, error) {
+	sess := newSession(cfg)
+	lf, err := loadLockFile()
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := fetchTemplate(sess, name, t, false, lockedEntry(sess.log, lf, name, t))
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadManifest(res.Dir, t.Template.Path)
+}
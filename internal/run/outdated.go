@@ -0,0 +1,209 @@
+package run
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/git"
+	"github.com/CyberDuck79/duckfile/internal/source"
+)
+
+// RefStatus describes where a target's template ref stands against the tags of its repository.
+type RefStatus struct {
+	Target string
+	Ref    string // template.ref as written
+	Tag    string // tag a version constraint ref resolves to
+	Commit string // commit the ref resolves to now
+	Latest string // newest stable semver tag in the repository, if any
+	Update string // ref `duck update` would write; empty when up to date or not a version
+	Note   string // why the target was not checked
+	Err    error
+}
+
+// Outdated resolves every selected Git target's ref against the remote and compares
+// it with the newest semver tag. Other source kinds are reported with a note.
+func Outdated(cfg *config.DuckConf, targetName string) ([]RefStatus, error) {
+	targets, err := collectTargets(cfg, targetName)
+	if err != nil {
+		return nil, err
+	}
+	sess := newSession(cfg)
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tagsByRepo := map[string]map[string]string{}
+	statuses := make([]RefStatus, 0, len(names))
+	for _, name := range names {
+		t := targets[name]
+		st := RefStatus{Target: name, Ref: t.Template.Ref}
+		if kind := source.Kind(t.Template); kind != source.KindGit {
+			st.Note = kind + " source"
+			statuses = append(statuses, st)
+			continue
+		}
+		if err := checkAllowedHost(sess.settings, t.Template.Repo); err != nil {
+			st.Err = err
+			statuses = append(statuses, st)
+			continue
+		}
+		repo := git.NormalizeURL(t.Template.Repo)
+		tags, ok := tagsByRepo[repo]
+		if !ok {
+			sess.log.Debugf("listing tags of %s", t.Template.Repo)
+			if tags, err = git.ListTags(t.Template.Repo); err != nil {
+				st.Err = err
+				statuses = append(statuses, st)
+				continue
+			}
+			tagsByRepo[repo] = tags
+		}
+		st.Latest = git.LatestVersion(tags)
+		st.Err = checkRef(&st, t.Template.Repo, tags)
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// checkRef fills in the commit, the resolved tag and the proposed update of st.
+func checkRef(st *RefStatus, repo string, tags map[string]string) error {
+	if git.IsVersionConstraint(st.Ref) {
+		tag, err := git.MatchConstraint(tags, st.Ref)
+		if err != nil {
+			return err
+		}
+		st.Tag, st.Commit = tag, tags[tag]
+		if c, _ := semver.NewConstraint(st.Ref); st.Latest != "" && !c.Check(semver.MustParse(st.Latest)) {
+			st.Update = bumpConstraint(st.Ref, st.Latest)
+		}
+		return nil
+	}
+	commit, err := git.ResolveRef(repo, st.Ref)
+	if err != nil {
+		return err
+	}
+	st.Commit = commit
+	if _, isTag := tags[st.Ref]; isTag && st.Latest != "" {
+		if cur, err := semver.NewVersion(st.Ref); err == nil && semver.MustParse(st.Latest).GreaterThan(cur) {
+			st.Update = st.Latest
+		}
+	}
+	return nil
+}
+
+// bumpConstraint rewrites a constraint that excludes latest: ^ and ~ constraints
+// keep their operator and move to latest, anything else is replaced by the tag.
+func bumpConstraint(constraint, latest string) string {
+	for _, op := range []string{"^", "~"} {
+		if strings.HasPrefix(constraint, op) && !strings.ContainsAny(constraint, " ,|") {
+			return op + strings.TrimPrefix(latest, "v")
+		}
+	}
+	return latest
+}
+
+// Update rewrites template.ref in the config file at path, keeping its comments and
+// layout, then re-locks (when duck.lock exists) and syncs the updated targets. With
+// to set, targetName's ref becomes to; otherwise every selected target with a newer
+// tag available is moved to it as reported by Outdated.
+func Update(cfg *config.DuckConf, path, targetName, to string) ([]SyncResult, error) {
+	sess := newSession(cfg)
+	updates := map[string]string{}
+	if to != "" {
+		if targetName == "" {
+			return nil, fmt.Errorf("--to requires a target")
+		}
+		targets, err := collectTargets(cfg, targetName)
+		if err != nil {
+			return nil, err
+		}
+		t := targets[targetName]
+		if source.Kind(t.Template) != source.KindGit {
+			return nil, fmt.Errorf("target %q: only git template refs can be updated", targetName)
+		}
+		if err := checkAllowedHost(sess.settings, t.Template.Repo); err != nil {
+			return nil, err
+		}
+		if git.IsVersionConstraint(to) {
+			_, _, err = git.ResolveConstraint(t.Template.Repo, to)
+		} else {
+			_, err = git.ResolveRef(t.Template.Repo, to)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("target %q: %w", targetName, err)
+		}
+		updates[targetName] = to
+	} else {
+		statuses, err := Outdated(cfg, targetName)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range statuses {
+			if st.Err != nil {
+				return nil, fmt.Errorf("target %q: %w", st.Target, st.Err)
+			}
+			if st.Update != "" {
+				updates[st.Target] = st.Update
+			}
+		}
+	}
+	if len(updates) == 0 {
+		sess.log.Infof("all template refs are up to date")
+		return nil, nil
+	}
+
+	doc, err := config.LoadDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(updates))
+	for name := range updates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	targets, _ := collectTargets(cfg, "")
+	for _, name := range names {
+		if err := doc.SetTemplateRef(name, updates[name]); err != nil {
+			return nil, err
+		}
+		sess.log.Infof("updated %s: %s -> %s", name, refOrHead(targets[name].Template.Ref), updates[name])
+	}
+	if err := doc.Save(path); err != nil {
+		return nil, err
+	}
+
+	if cfg, err = config.Load(path); err != nil {
+		return nil, err
+	}
+	lf, err := loadLockFile()
+	if err != nil {
+		return nil, err
+	}
+	var results []SyncResult
+	for _, name := range names {
+		if lf != nil {
+			if err := Lock(cfg, name, true); err != nil {
+				return results, err
+			}
+		}
+		res, err := Sync(cfg, name, SyncOptions{Jobs: 1})
+		results = append(results, res...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func refOrHead(ref string) string {
+	if strings.TrimSpace(ref) == "" {
+		return "HEAD"
+	}
+	return ref
+}
@@ -0,0 +1,29 @@
+package run
+
+import (
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+// Remove deletes a named target: its cached files and symlink are cleaned first,
+// then the target is removed from the config file at path (keeping the rest of the
+// file as written) and its duck.lock entry, if any, is dropped.
+func Remove(cfg *config.DuckConf, path, targetName string) error {
+	doc, err := config.LoadDocument(path)
+	if err != nil {
+		return err
+	}
+	if err := doc.RemoveTarget(targetName); err != nil {
+		return err
+	}
+	if err := Clean(cfg, targetName); err != nil {
+		return err
+	}
+	if err := doc.Save(path); err != nil {
+		return err
+	}
+	if err := unlockTarget(targetName); err != nil {
+		return err
+	}
+	newSession(cfg).log.Infof("removed target %s", targetName)
+	return nil
+}
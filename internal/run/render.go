@@ -0,0 +1,65 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+// RenderOptions tunes Render.
+type RenderOptions struct {
+	// Set overrides target variables.
+	Set map[string]config.VarValue
+	// TemplateFile renders this local file instead of the target's remote template.
+	TemplateFile string
+}
+
+// Render renders one target's template with its resolved variables and returns the
+// output. No cache object is written and no symlink is touched; remote templates
+// are still fetched through the shared repository store.
+func Render(cfg *config.DuckConf, targetName string, opts RenderOptions) ([]byte, error) {
+	if targetName == "" {
+		targetName = "default"
+	}
+	targets, err := collectTargets(cfg, targetName)
+	if err != nil {
+		return nil, err
+	}
+	t := targets[targetName]
+	sess := newSession(cfg)
+	sess.overrides = opts.Set
+
+	var name string
+	var raw []byte
+	var manifest *config.Manifest
+	if opts.TemplateFile != "" {
+		name = filepath.Base(opts.TemplateFile)
+		if raw, err = os.ReadFile(opts.TemplateFile); err != nil {
+			return nil, err
+		}
+		if manifest, err = config.LoadManifest(filepath.Dir(opts.TemplateFile), name); err != nil {
+			return nil, err
+		}
+	} else {
+		lf, err := loadLockFile()
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.Base(t.Template.Path)
+		res, b, err := fetchTemplate(sess, targetName, t, false, lockedEntry(sess.log, lf, targetName, t))
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+		if manifest, err = config.LoadManifest(res.Dir, t.Template.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	vars, err := resolveTargetVariables(sess, targetName, t, manifest)
+	if err != nil {
+		return nil, err
+	}
+	return executeTemplate(name, raw, t, vars)
+}
@@ -2,13 +2,11 @@ package run
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"os"
 	"os/exec"
@@ -17,12 +15,15 @@ import (
 	"text/template"
 
 	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/fsutil"
 	"github.com/CyberDuck79/duckfile/internal/git"
+	"github.com/CyberDuck79/duckfile/internal/lock"
+	"github.com/CyberDuck79/duckfile/internal/source"
 	sprig "github.com/Masterminds/sprig/v3"
 )
 
-// Exec renders and executes one target.
-func Exec(cfg *config.DuckConf, targetName string, passthrough []string) error {
+// Exec renders and executes one target. set overrides target variables.
+func Exec(cfg *config.DuckConf, targetName string, passthrough []string, set map[string]config.VarValue) error {
 	t := cfg.Default
 	if targetName != "" && targetName != "default" {
 		var ok bool
@@ -37,203 +38,475 @@ func Exec(cfg *config.DuckConf, targetName string, passthrough []string) error {
 			targetOrDefault(targetName, "default"), optTargetSuffix(targetName))
 	}
 
-	// 1. Resolve variables first (no need to clone to do this)
-	vars, err := resolveVariables(t.Variables)
+	sess := newSession(cfg)
+	sess.overrides = set
+	lf, err := loadLockFile()
 	if err != nil {
 		return err
 	}
+	pin := lockedEntry(sess.log, lf, targetOrDefault(targetName, "default"), t)
 
-	// 2. Compute deterministic cache key and object path
-	base := strings.TrimSuffix(filepath.Base(t.Template.Path), ".tpl")
-
-	key, err := computeCacheKey(t.Template.Repo, t.Template.Ref, t.Template.Path, vars)
+	// 1. Resolve variables, pin the commit, render if needed and update the symlink
+	p, err := syncTarget(sess, targetOrDefault(targetName, "default"), t, false, pin)
 	if err != nil {
 		return err
 	}
-	objDir := filepath.Join(".duck", "objects", key)
-	objFile := filepath.Join(objDir, base)
-	// Ensure objects dir exists only if we will write into it later.
+	linkPath := p.linkPath
+	migrateLegacyObjects(sess)
 
-	// 3. Prepare per-target cache dir and compute symlink path
-	cacheDir := filepath.Join(".duck", targetOrDefault(targetName, "default"))
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return err
+	// 2. Execute underlying binary with the symlink
+	// Order: [fileFlag linkPath] + target default args + user passthrough args
+	args := append([]string{t.FileFlag, linkPath}, []string(t.Args)...)
+	args = append(args, passthrough...)
+	cmd := exec.Command(t.Binary, args...)
+	cmd.Stdout, cmd.Stderr, cmd.Stdin = os.Stdout, os.Stderr, os.Stdin
+	return cmd.Run()
+}
+
+func targetOrDefault(t, d string) string {
+	if t == "" {
+		return d
 	}
-	linkPath := t.RenderedPath
-	if linkPath == "" {
-		linkPath = filepath.Join(cacheDir, base) // per-target path
+	return t
+}
+
+// objectBase is the file name of a target's rendered object: the template's base name
+// without its .tpl suffix.
+func objectBase(t config.Target) string {
+	return strings.TrimSuffix(filepath.Base(t.Template.Path), ".tpl")
+}
+
+// targetLinkPath is where a target's symlink lives: renderedPath, or the per-target
+// path .duck/<target>/<base>.
+func targetLinkPath(name string, t config.Target) string {
+	if t.RenderedPath != "" {
+		return t.RenderedPath
 	}
+	return filepath.Join(".duck", name, objectBase(t))
+}
 
-	// 4. If object is missing, fetch template repo and render it; otherwise, skip cloning
-	if _, statErr := os.Stat(objFile); statErr != nil {
-		// Fetch template repository at the requested ref
-		repoDir, err := git.CloneInto(t.Template.Repo, t.Template.Ref, cacheDir)
-		if err != nil {
-			return err
+// preparedTarget holds everything needed to render and link one target.
+type preparedTarget struct {
+	name     string
+	vars     map[string]any
+	commit   string // commit SHA the template was read from
+	version  string // tag a version constraint ref resolved to, if any
+	raw      []byte // raw template bytes at commit
+	key      string
+	objFile  string
+	linkPath string
+	oldKey   string // key of the object currently behind linkPath, if any
+	rendered bool   // set once the object has been (re-)rendered
+}
+
+// prepareTarget pins the template ref to a commit, reads the raw template and its
+// manifest, resolves variables and computes the cache key. refresh forces a new ref
+// resolution; a non-nil pin (from duck.lock) bypasses resolution entirely.
+func prepareTarget(sess *session, name string, t config.Target, refresh bool, pin *lock.Entry) (*preparedTarget, error) {
+	res, raw, err := fetchTemplate(sess, name, t, refresh, pin)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := config.LoadManifest(res.Dir, t.Template.Path)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := resolveTargetVariables(sess, name, t, manifest)
+	if err != nil {
+		return nil, err
+	}
+	base := objectBase(t)
+	if err := os.MkdirAll(filepath.Join(".duck", name), 0o755); err != nil {
+		return nil, err
+	}
+	linkPath := targetLinkPath(name, t)
+
+	key, err := computeCacheKey(t.Template, res.Revision, res.Version, raw, vars)
+	if err != nil {
+		return nil, err
+	}
+	oldKey := detectKeyFromSymlink(linkPath, sess.settings.ObjectsDir())
+	if sess.settings.Locked && isLegacyKey(oldKey) {
+		// Key format changed (SHA-1 -> SHA-256): nothing to compare against, migrate
+		sess.log.Warnf("target %s: migrating legacy cache key %s; locked mode cannot verify this change", name, oldKey)
+	} else if err := checkLocked(sess.settings, name, oldKey, key); err != nil {
+		return nil, err
+	}
+	return &preparedTarget{
+		name:     name,
+		vars:     vars,
+		commit:   res.Revision,
+		version:  res.Version,
+		raw:      raw,
+		key:      key,
+		objFile:  filepath.Join(sess.settings.ObjectsDir(), key, base),
+		linkPath: linkPath,
+		oldKey:   oldKey,
+	}, nil
+}
+
+// fetchTemplate pins the template ref to a revision (or uses pin), materializes it
+// and returns the fetch result with the verified raw template bytes.
+// Local templates are read in place and have no revision.
+func fetchTemplate(sess *session, name string, t config.Target, refresh bool, pin *lock.Entry) (source.Result, []byte, error) {
+	var rev string
+	if pin != nil {
+		rev = pin.Commit
+	}
+	res, err := fetchSource(sess, t.Template, refresh, rev)
+	if err != nil {
+		return source.Result{}, nil, err
+	}
+	if pin != nil {
+		res.Version = pin.Tag
+	}
+	raw, err := os.ReadFile(filepath.Join(res.Dir, t.Template.Path))
+	if err != nil {
+		return source.Result{}, nil, err
+	}
+	if err := verifyChecksum(t.Template, raw); err != nil {
+		return source.Result{}, nil, fmt.Errorf("target %q: %w", name, err)
+	}
+	if pin != nil {
+		if sum := sha256Hex(raw); sum != pin.SHA256 {
+			return source.Result{}, nil, fmt.Errorf("target %q: template %s@%s has sha256 %s but duck.lock pins %s", name, t.Template.Path, res.Revision, sum, pin.SHA256)
 		}
-		src := filepath.Join(repoDir, t.Template.Path)
-		if err := os.MkdirAll(objDir, 0o755); err != nil {
-			return err
+	}
+	return res, raw, nil
+}
+
+// fetchSource fetches a template's source at its ref, or at pin when set. Each
+// source@ref is fetched once per session, however many targets share it.
+func fetchSource(sess *session, tpl config.Template, refresh bool, pin string) (source.Result, error) {
+	kind := source.Kind(tpl)
+	if kind != source.KindLocal {
+		if err := checkAllowedHost(sess.settings, tpl.Repo); err != nil {
+			return source.Result{}, err
 		}
-		if err := renderTemplate(src, objFile, t, vars); err != nil {
-			return err
+	}
+	src, err := source.New(tpl, sourceOptions(sess))
+	if err != nil {
+		return source.Result{}, err
+	}
+	id := tpl.Source()
+	if kind == source.KindGit {
+		id = git.NormalizeURL(tpl.Repo)
+	}
+	key := fmt.Sprintf("%s|%s|%s|%s|%t|%t|%t", kind, id, tpl.Ref, pin, refresh, tpl.IsShallow(), tpl.Submodules)
+	return sess.fetched.do(key, func() (source.Result, error) {
+		return src.Fetch(source.Request{Ref: tpl.Ref, Pin: pin, Refresh: refresh})
+	})
+}
+
+// refsFile remembers ref resolutions (see source.Options.RefsFile).
+var refsFile = filepath.Join(".duck", "refs.json")
+
+func sourceOptions(sess *session) source.Options {
+	return source.Options{
+		CacheDir:  sess.settings.ReposDir(),
+		RefsFile:  refsFile,
+		RefMaxAge: sess.settings.RefRefresh(),
+		Log:       sess.log,
+	}
+}
+
+// verifyChecksum compares the raw template with template.checksum, if set.
+func verifyChecksum(tpl config.Template, raw []byte) error {
+	want := strings.ToLower(strings.TrimSpace(tpl.Checksum))
+	if want == "" {
+		return nil
+	}
+	if got := sha256Hex(raw); got != want {
+		return fmt.Errorf("checksum mismatch for %s in %s: expected sha256 %s, got %s; the template changed upstream or was tampered with, refusing to render", tpl.Path, tpl.Source(), want, got)
+	}
+	return nil
+}
+
+// renderObject renders the prepared template into its object file.
+func renderObject(sess *session, t config.Target, p *preparedTarget) error {
+	sess.log.Debugf("rendering %s@%s into %s", t.Template.Path, refOrLocal(p.commit), p.objFile)
+	return renderTemplate(filepath.Base(t.Template.Path), p.raw, p.objFile, t, p.vars)
+}
+
+// linkObject points the target's symlink at the current object and removes the
+// object previously referenced, if it changed and no other target links to it.
+// The caller holds the objects lock.
+func linkObject(sess *session, p *preparedTarget) error {
+	if err := ensureSymlink(p.objFile, p.linkPath); err != nil {
+		return err
+	}
+	if p.oldKey != "" && p.oldKey != p.key {
+		dir := sess.settings.ObjectsDir()
+		if referencedObjects(sess.links, dir)[p.oldKey] {
+			sess.log.Debugf("keeping object %s, still linked by another target", p.oldKey)
+			return nil
 		}
+		sess.log.Debugf("removing stale object %s", p.oldKey)
+		_ = os.RemoveAll(filepath.Join(dir, p.oldKey))
 	}
+	return nil
+}
 
-	// 5. Determine previous key from existing symlink (if any)
-	oldKey := ""
-	if fi, err := os.Lstat(linkPath); err == nil && (fi.Mode()&os.ModeSymlink) != 0 {
-		if dest, err := os.Readlink(linkPath); err == nil {
-			// Resolve relative symlink to absolute
-			if !filepath.IsAbs(dest) {
-				dest = filepath.Join(filepath.Dir(linkPath), dest)
-			}
-			if abs, err := filepath.Abs(dest); err == nil {
-				// abs is .../.duck/objects/<key>/<base> ideally
-				objDirPrev := filepath.Dir(abs)
-				objectsDir := filepath.Base(filepath.Dir(objDirPrev))
-				if objectsDir == "objects" {
-					oldKey = filepath.Base(objDirPrev)
-				}
-			}
+// referencedObjects returns the keys of the objects the given symlinks point to.
+func referencedObjects(links map[string]string, objectsDir string) map[string]bool {
+	referenced := map[string]bool{}
+	for _, link := range links {
+		if key := detectKeyFromSymlink(link, objectsDir); key != "" {
+			referenced[key] = true
 		}
 	}
+	return referenced
+}
 
-	// 6. Create/update symlink to the current object
-	if err := ensureSymlink(objFile, linkPath); err != nil {
-		return err
+// checkAllowedHost enforces settings.allowedHosts before any network access.
+func checkAllowedHost(settings config.Settings, repo string) error {
+	if len(settings.AllowedHosts) == 0 {
+		return nil
+	}
+	host := git.RepoHost(repo)
+	for _, h := range settings.AllowedHosts {
+		if strings.EqualFold(strings.TrimSpace(h), host) {
+			return nil
+		}
 	}
+	if host == "" {
+		return fmt.Errorf("repo %q has no host; allowedHosts only permits %v", repo, settings.AllowedHosts)
+	}
+	return fmt.Errorf("host %q of repo %q is not in allowedHosts %v", host, repo, settings.AllowedHosts)
+}
 
-	// 7. If the key changed, remove the old object directory to free cache
-	if oldKey != "" && oldKey != key {
-		_ = os.RemoveAll(filepath.Join(".duck", "objects", oldKey))
+// checkLocked fails in locked mode when the computed key differs from the one behind the symlink.
+func checkLocked(settings config.Settings, targetName, oldKey, key string) error {
+	if !settings.Locked || oldKey == "" || oldKey == key {
+		return nil
 	}
+	return fmt.Errorf("target %q: template or variables changed (cache key %s -> %s) and settings.locked is true; unlock or update the rendered object explicitly", targetName, oldKey, key)
+}
 
-	// 8. Execute underlying binary with the symlink
-	// Order: [fileFlag linkPath] + target default args + user passthrough args
-	args := append([]string{t.FileFlag, linkPath}, []string(t.Args)...)
-	args = append(args, passthrough...)
-	cmd := exec.Command(t.Binary, args...)
-	cmd.Stdout, cmd.Stderr, cmd.Stdin = os.Stdout, os.Stderr, os.Stdin
-	return cmd.Run()
+func refOrLocal(commit string) string {
+	if commit == "" {
+		return "local"
+	}
+	return commit
 }
 
-func targetOrDefault(t, d string) string {
-	if t == "" {
-		return d
+// templateDelims returns the effective delimiters: {{ }} unless overridden.
+func templateDelims(tpl config.Template) (string, string) {
+	left, right := "{{", "}}"
+	if tpl.Delims != nil {
+		if l := strings.TrimSpace(tpl.Delims.Left); l != "" {
+			left = l
+		}
+		if r := strings.TrimSpace(tpl.Delims.Right); r != "" {
+			right = r
+		}
 	}
-	return t
+	return left, right
 }
 
-func renderTemplate(src, dst string, targ config.Target, data map[string]any) error {
-	raw, err := os.ReadFile(src)
+// missingKeyPolicy returns the text/template missingkey option for tpl.
+func missingKeyPolicy(tpl config.Template) string {
+	if tpl.AllowMissing {
+		return "zero"
+	}
+	return "error"
+}
+
+func renderTemplate(name string, raw []byte, dst string, targ config.Target, data map[string]any) error {
+	out, err := executeTemplate(name, raw, targ, data)
 	if err != nil {
 		return err
 	}
+	return fsutil.WriteFileAtomic(dst, out, 0o644)
+}
 
-	// Build template with sprig functions and a small set of extras
+// templateFuncs returns the functions templates may call: sprig and a small set
+// of extras.
+func templateFuncs() template.FuncMap {
 	funcMap := sprig.TxtFuncMap()
 	funcMap["now"] = time.Now
 	funcMap["env"] = os.Getenv
+	return funcMap
+}
 
+// executeTemplate renders raw with the target's delimiters and missing-key policy.
+func executeTemplate(name string, raw []byte, targ config.Target, data map[string]any) ([]byte, error) {
 	// Delimiters: default {{ }}, overridable by config
-	left, right := "{{", "}}"
-	if targ.Template.Delims != nil {
-		if l := strings.TrimSpace(targ.Template.Delims.Left); l != "" {
-			left = l
-		}
-		if r := strings.TrimSpace(targ.Template.Delims.Right); r != "" {
-			right = r
-		}
-	}
-
-	tmpl := template.New(filepath.Base(src)).Funcs(funcMap).Delims(left, right)
+	left, right := templateDelims(targ.Template)
+	tmpl := template.New(name).Funcs(templateFuncs()).Delims(left, right)
 
 	// Missing-key policy: allowMissing => zero (empty strings), else strict error
-	if targ.Template.AllowMissing {
-		tmpl = tmpl.Option("missingkey=zero")
-	} else {
-		tmpl = tmpl.Option("missingkey=error")
-	}
+	tmpl = tmpl.Option("missingkey=" + missingKeyPolicy(targ.Template))
 
 	tpl, err := tmpl.Parse(string(raw))
 	if err != nil {
-		return fmt.Errorf("parse template: %w", err)
+		return nil, fmt.Errorf("parse template: %w", err)
 	}
 
 	var buf bytes.Buffer
 	if err := tpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("execute template: %w", err)
+		return nil, fmt.Errorf("execute template: %w", err)
 	}
+	return buf.Bytes(), nil
+}
 
-	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
-		return err
+// targetVariables merges the variables of a target, each layer taking precedence
+// over the previous ones: global varsFiles, the target's varsFiles (both in
+// declared order), inline variables and command-line overrides.
+func targetVariables(sess *session, t config.Target) (map[string]config.VarValue, error) {
+	merged := map[string]config.VarValue{}
+	for _, f := range append(append([]string{}, sess.varsFiles...), t.VarsFiles...) {
+		vars, err := config.LoadVarsFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range vars {
+			merged[k] = layerVar(merged[k], v)
+		}
+	}
+	for _, layer := range []map[string]config.VarValue{t.Variables, sess.overrides} {
+		for k, v := range layer {
+			merged[k] = layerVar(merged[k], v)
+		}
 	}
-	return os.WriteFile(dst, buf.Bytes(), 0o644)
+	return merged, nil
+}
+
+// layerVar puts v over prev. A `!var` declaration without a value keeps the value
+// of prev, and a value without a declaration keeps the declaration of prev.
+func layerVar(prev, v config.VarValue) config.VarValue {
+	spec := v.Spec
+	if spec == nil {
+		spec = prev.Spec
+	}
+	if !v.IsSet() {
+		v = prev
+	}
+	v.Spec = spec
+	return v
+}
+
+// resolveTargetVariables resolves the merged variables of target name and checks
+// them against its template manifest m, when there is one.
+func resolveTargetVariables(sess *session, name string, t config.Target, m *config.Manifest) (map[string]any, error) {
+	merged, err := targetVariables(sess, t)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := resolveVariables(merged)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyManifest(sess, name, t, m, vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
 }
 
 func resolveVariables(in map[string]config.VarValue) (map[string]any, error) {
 	out := make(map[string]any, len(in))
 	for k, v := range in {
-		switch v.Kind {
-		case config.VarLiteral:
-			out[k] = v.Value
-		case config.VarEnv:
-			out[k] = os.Getenv(v.Arg)
-		case config.VarFile:
-			b, err := os.ReadFile(v.Arg)
+		val, err := ResolveVariable(k, v)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = val
+	}
+	return out, nil
+}
+
+// ResolveVariable returns the value template key k receives from v: the literal,
+// the environment variable, the file content or the command output. Mappings and
+// sequences are resolved leaf by leaf into map[string]any and []any. A declared
+// variable is then checked against its declaration (see checkDeclared).
+func ResolveVariable(k string, v config.VarValue) (any, error) {
+	if v.Spec == nil {
+		return resolveValue(k, v)
+	}
+	var val any
+	if v.IsSet() {
+		var err error
+		if val, err = resolveValue(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return checkDeclared(k, *v.Spec, val)
+}
+
+func resolveValue(k string, v config.VarValue) (any, error) {
+	switch v.Kind {
+	case config.VarLiteral:
+		return v.Value, nil
+	case config.VarMap:
+		out := make(map[string]any, len(v.Map))
+		for ck, cv := range v.Map {
+			val, err := ResolveVariable(k+"."+ck, cv)
 			if err != nil {
-				return nil, fmt.Errorf("read file for var %s: %w", k, err)
+				return nil, err
 			}
-			out[k] = string(b)
-		case config.VarCmd:
-			// Execute with /bin/sh -c to match spec
-			cmd := exec.Command("/bin/sh", "-c", v.Arg)
-			cmd.Env = os.Environ()
-			outb, err := cmd.Output()
+			out[ck] = val
+		}
+		return out, nil
+	case config.VarList:
+		out := make([]any, 0, len(v.List))
+		for i, cv := range v.List {
+			val, err := ResolveVariable(fmt.Sprintf("%s[%d]", k, i), cv)
 			if err != nil {
-				// bubble up stderr if possible
-				if ee, ok := err.(*exec.ExitError); ok {
-					return nil, fmt.Errorf("cmd var %s failed: %v: %s", k, err, string(ee.Stderr))
-				}
-				return nil, fmt.Errorf("cmd var %s failed: %w", k, err)
+				return nil, err
 			}
-			// Trim trailing newline for typical CLI output
-			out[k] = strings.TrimRight(string(outb), "\r\n")
-		default:
-			out[k] = v.Value
+			out = append(out, val)
+		}
+		return out, nil
+	case config.VarEnv:
+		return os.Getenv(v.Arg), nil
+	case config.VarFile:
+		b, err := os.ReadFile(v.Arg)
+		if err != nil {
+			return nil, fmt.Errorf("read file for var %s: %w", k, err)
+		}
+		return string(b), nil
+	case config.VarCmd:
+		outb, err := runVarCmd(k, v.Arg)
+		if err != nil {
+			return nil, err
+		}
+		// Trim trailing newline for typical CLI output
+		return strings.TrimRight(string(outb), "\r\n"), nil
+	}
+	if format, fromCmd, ok := v.Kind.Data(); ok {
+		var b []byte
+		var err error
+		if fromCmd {
+			b, err = runVarCmd(k, v.Arg)
+		} else if b, err = os.ReadFile(v.Arg); err != nil {
+			err = fmt.Errorf("read file for var %s: %w", k, err)
+		}
+		if err != nil {
+			return nil, err
 		}
+		val, err := decodeData(format, b)
+		if err != nil {
+			return nil, fmt.Errorf("var %s: parse %s: %w", k, format, err)
+		}
+		return val, nil
 	}
-	return out, nil
+	return v.Value, nil
 }
 
-// computeCacheKey builds a stable SHA1 over repo/ref/path and resolved vars.
-func computeCacheKey(repo, ref, path string, vars map[string]any) (string, error) {
-	keys := make([]string, 0, len(vars))
-	for k := range vars {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	type kv struct {
-		K string      `json:"k"`
-		V interface{} `json:"v"`
-	}
-	pairs := make([]kv, 0, len(keys))
-	for _, k := range keys {
-		pairs = append(pairs, kv{K: k, V: vars[k]})
-	}
-	payload := map[string]any{
-		"repo": repo,
-		"ref":  ref,
-		"path": path,
-		"vars": pairs,
-	}
-	b, err := json.Marshal(payload)
+// runVarCmd runs the command of variable k and returns its standard output.
+func runVarCmd(k, command string) ([]byte, error) {
+	// Execute with /bin/sh -c to match spec
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = os.Environ()
+	outb, err := cmd.Output()
 	if err != nil {
-		return "", err
+		// bubble up stderr if possible
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("cmd var %s failed: %v: %s", k, err, string(ee.Stderr))
+		}
+		return nil, fmt.Errorf("cmd var %s failed: %w", k, err)
 	}
-	sum := sha1.Sum(b)
-	return hex.EncodeToString(sum[:]), nil
+	return outb, nil
 }
 
 func ensureSymlink(target, link string) error {
@@ -255,90 +528,161 @@ func ensureSymlink(target, link string) error {
 		targetForLink = relTarget
 	}
 
-	// If a link/file exists, replace it unless it already matches
-	if fi, err := os.Lstat(link); err == nil {
-		if fi.Mode()&os.ModeSymlink != 0 {
-			if dest, err := os.Readlink(link); err == nil && dest == targetForLink {
-				return nil // already correct
-			}
-		}
-		if err := os.Remove(link); err != nil {
-			return err
+	// If a link already matches, keep it; otherwise atomically replace whatever is there
+	if fi, err := os.Lstat(link); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if dest, err := os.Readlink(link); err == nil && dest == targetForLink {
+			return nil // already correct
 		}
 	}
 
-	return os.Symlink(targetForLink, link)
+	return fsutil.SymlinkAtomic(targetForLink, link)
+}
+
+// SyncOptions tunes Sync.
+type SyncOptions struct {
+	// Force re-renders regardless of existing cache.
+	Force bool
+	// Frozen fails when duck.lock is missing or stale instead of resolving refs.
+	Frozen bool
+	// Jobs is the number of targets synced concurrently. Values below 1 mean 1.
+	Jobs int
+	// Set overrides variables of every synced target. Resolved overrides are part
+	// of the cache key like any other variable.
+	Set map[string]config.VarValue
+}
+
+// Sync outcomes reported in SyncResult.Status.
+const (
+	StatusRendered = "rendered"
+	StatusCached   = "cached"
+	StatusFailed   = "failed"
+)
+
+// SyncResult is the outcome of syncing one target.
+type SyncResult struct {
+	Target string
+	Status string
+	Commit string // template commit SHA, empty on failure
+	Key    string // cache key of the linked object, empty on failure
+	Err    error
 }
 
 // Sync renders templates into the cache without executing the target.
-// If targetName is empty, all targets (default + named) are synced.
-// If force is true, re-render regardless of existing cache.
-func Sync(cfg *config.DuckConf, targetName string, force bool) error {
+// If targetName is empty, all targets (default + named) are synced, opts.Jobs at a
+// time. A failing target does not stop the others; results are returned sorted by
+// target name, and the error reports how many targets failed.
+func Sync(cfg *config.DuckConf, targetName string, opts SyncOptions) ([]SyncResult, error) {
 	targets, err := collectTargets(cfg, targetName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for name, t := range targets {
-		if err := syncOne(name, t, force); err != nil {
-			return err
+	sess := newSession(cfg)
+	sess.overrides = opts.Set
+	lf, err := loadLockFile()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Frozen {
+		if err := checkFrozen(cfg, lf, targetName); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	results := make([]SyncResult, len(names))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs && w < len(names); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				name, t := names[i], targets[names[i]]
+				res := SyncResult{Target: name}
+				p, err := syncTarget(sess, name, t, opts.Force, lockedEntry(sess.log, lf, name, t))
+				switch {
+				case err != nil:
+					res.Status, res.Err = StatusFailed, err
+				case p.rendered:
+					res.Status, res.Commit, res.Key = StatusRendered, p.commit, p.key
+				default:
+					res.Status, res.Commit, res.Key = StatusCached, p.commit, p.key
+				}
+				results[i] = res
+			}
+		}()
+	}
+	for i := range names {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	migrateLegacyObjects(sess)
+
+	var failed []SyncResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	switch {
+	case len(failed) == 0:
+		return results, nil
+	case len(results) == 1:
+		return results, failed[0].Err
+	default:
+		return results, fmt.Errorf("%d of %d targets failed to sync", len(failed), len(results))
+	}
 }
 
-func syncOne(targetName string, t config.Target, force bool) error {
-	// Resolve variables and compute key/paths
-	vars, err := resolveVariables(t.Variables)
+// syncTarget renders one target into the cache (if needed) and updates its symlink
+// while holding the target's cross-process lock.
+func syncTarget(sess *session, name string, t config.Target, force bool, pin *lock.Entry) (*preparedTarget, error) {
+	lk, err := acquireTargetLock(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	base := strings.TrimSuffix(filepath.Base(t.Template.Path), ".tpl")
-	key, err := computeCacheKey(t.Template.Repo, t.Template.Ref, t.Template.Path, vars)
+	defer lk.Unlock()
+
+	// Resolve variables, pin the template commit and compute key/paths
+	p, err := prepareTarget(sess, name, t, force, pin)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	objDir := filepath.Join(".duck", "objects", key)
-	objFile := filepath.Join(objDir, base)
 
-	cacheDir := filepath.Join(".duck", targetOrDefault(targetName, "default"))
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return err
-	}
-	linkPath := t.RenderedPath
-	if linkPath == "" {
-		linkPath = filepath.Join(cacheDir, base)
+	// Objects are shared by targets with the same key: another target must not
+	// remove the object between its render and the link to it
+	olk, err := acquireObjectsLock()
+	if err != nil {
+		return nil, err
 	}
+	defer olk.Unlock()
 
 	needRender := force
 	if !needRender {
-		if _, err := os.Stat(objFile); err != nil {
+		if _, err := os.Stat(p.objFile); err != nil {
 			needRender = true
 		}
 	}
 	if needRender {
-		// Always fetch/clone then render
-		repoDir, err := git.CloneInto(t.Template.Repo, t.Template.Ref, cacheDir)
-		if err != nil {
-			return err
-		}
-		src := filepath.Join(repoDir, t.Template.Path)
-		if err := os.MkdirAll(objDir, 0o755); err != nil {
-			return err
+		if err := renderObject(sess, t, p); err != nil {
+			return nil, err
 		}
-		if err := renderTemplate(src, objFile, t, vars); err != nil {
-			return err
-		}
-	}
-
-	// Detect previous key via symlink before updating
-	oldKey := detectKeyFromSymlink(linkPath)
-	if err := ensureSymlink(objFile, linkPath); err != nil {
-		return err
-	}
-	if oldKey != "" && oldKey != key {
-		_ = os.RemoveAll(filepath.Join(".duck", "objects", oldKey))
+		p.rendered = true
+	} else {
+		sess.log.Debugf("cache hit for target %s (%s)", p.name, p.key)
 	}
-	return nil
+	return p, linkObject(sess, p)
 }
 
 // Clean removes cached objects and per-target working dirs.
@@ -349,10 +693,14 @@ func Clean(cfg *config.DuckConf, targetName string) error {
 		// Remove per-target dirs and unlink symlinks
 		targets, _ := collectTargets(cfg, "")
 		for name, t := range targets {
-			_ = cleanOne(name, t)
+			_ = cleanOne(cfg.Settings, name, t, nil)
+		}
+		// Drop the repository store unless it is a custom (possibly shared) location
+		if strings.TrimSpace(cfg.Settings.RepoCacheDir) == "" {
+			_ = os.RemoveAll(config.DefaultRepoCacheDir)
 		}
 		// Finally, remove objects dir
-		return os.RemoveAll(filepath.Join(".duck", "objects"))
+		return os.RemoveAll(cfg.Settings.ObjectsDir())
 	}
 	t, ok := cfg.Targets[targetName]
 	if !ok && targetName != "default" && targetName != "" {
@@ -362,37 +710,53 @@ func Clean(cfg *config.DuckConf, targetName string) error {
 		t = cfg.Default
 		targetName = "default"
 	}
-	return cleanOne(targetName, t)
+	return cleanOne(cfg.Settings, targetName, t, targetLinks(cfg))
 }
 
-func cleanOne(targetName string, t config.Target) error {
-	base := strings.TrimSuffix(filepath.Base(t.Template.Path), ".tpl")
-	cacheDir := filepath.Join(".duck", targetOrDefault(targetName, "default"))
-	linkPath := t.RenderedPath
-	if linkPath == "" {
-		linkPath = filepath.Join(cacheDir, base)
+// cleanOne removes a target's symlink and working dir, and the object behind the
+// symlink unless one of links (the symlinks of the other targets) still points to it.
+func cleanOne(settings config.Settings, targetName string, t config.Target, links map[string]string) error {
+	lk, err := acquireTargetLock(targetOrDefault(targetName, "default"))
+	if err != nil {
+		return err
 	}
+	defer lk.Unlock()
+
+	cacheDir := filepath.Join(".duck", targetOrDefault(targetName, "default"))
+	linkPath := targetLinkPath(targetOrDefault(targetName, "default"), t)
 	// Remove symlink if it exists
 	if fi, err := os.Lstat(linkPath); err == nil && (fi.Mode()&os.ModeSymlink) != 0 {
-		// Remove the object pointed by this symlink as well
-		if key := detectKeyFromSymlink(linkPath); key != "" {
-			_ = os.RemoveAll(filepath.Join(".duck", "objects", key))
+		olk, err := acquireObjectsLock()
+		if err != nil {
+			return err
 		}
+		key := detectKeyFromSymlink(linkPath, settings.ObjectsDir())
 		_ = os.Remove(linkPath)
+		// Remove the object pointed by this symlink as well, unless it is shared
+		if key != "" && !referencedObjects(links, settings.ObjectsDir())[key] {
+			_ = os.RemoveAll(filepath.Join(settings.ObjectsDir(), key))
+		}
+		olk.Unlock()
 	}
-	// Remove per-target cache dir (cloned repo path etc.)
+	// Remove per-target cache dir; shared repository mirrors are kept
 	return os.RemoveAll(cacheDir)
 }
 
-func detectKeyFromSymlink(linkPath string) string {
+// detectKeyFromSymlink returns the cache key of the object behind linkPath,
+// provided the link points to <objectsDir>/<key>/<base>.
+func detectKeyFromSymlink(linkPath, objectsDir string) string {
 	if fi, err := os.Lstat(linkPath); err == nil && (fi.Mode()&os.ModeSymlink) != 0 {
 		if dest, err := os.Readlink(linkPath); err == nil {
 			if !filepath.IsAbs(dest) {
 				dest = filepath.Join(filepath.Dir(linkPath), dest)
 			}
+			absObjects, err := filepath.Abs(objectsDir)
+			if err != nil {
+				return ""
+			}
 			if abs, err := filepath.Abs(dest); err == nil {
 				objDirPrev := filepath.Dir(abs)
-				if filepath.Base(filepath.Dir(objDirPrev)) == "objects" {
+				if filepath.Dir(objDirPrev) == absObjects {
 					return filepath.Base(objDirPrev)
 				}
 			}
@@ -0,0 +1,66 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+func TestTargetVariablesPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"global.yaml": "A: global\nB: global\nC: global\nD: global\nE: global\nPORT: !var {type: int, default: 80}\n",
+		"global.env":  "B=global-env\nC=global-env\n",
+		"target.json": `{"C": "target-json", "D": "target-json"}`,
+		"target.env":  "D=target-env\nE=target-env\nPORT=8080\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+	sess := &session{
+		varsFiles: []string{path("global.yaml"), path("global.env")},
+		overrides: map[string]config.VarValue{"F": config.NewLiteralVar("override")},
+	}
+	target := config.Target{
+		VarsFiles: []string{path("target.json"), path("target.env")},
+		Variables: map[string]config.VarValue{
+			"E": config.NewLiteralVar("inline"),
+			"F": config.NewLiteralVar("inline"),
+		},
+	}
+
+	merged, err := targetVariables(sess, target)
+	if err != nil {
+		t.Fatalf("targetVariables() error = %v", err)
+	}
+	want := map[string]string{
+		"A":    "global",      // only the first global file sets it
+		"B":    "global-env",  // later global files win
+		"C":    "target-json", // target files win over global ones
+		"D":    "target-env",  // later target files win
+		"E":    "inline",      // inline variables win over every file
+		"F":    "override",    // command-line overrides win over everything
+		"PORT": "8080",
+	}
+	for k, v := range want {
+		if got := merged[k].Value; got != v {
+			t.Errorf("%s = %v, want %s", k, got, v)
+		}
+	}
+	// A value from a later file keeps the declaration of an earlier one
+	if spec := merged["PORT"].Spec; spec == nil || spec.Type != "int" {
+		t.Errorf("PORT lost its declaration: %+v", spec)
+	}
+}
+
+func TestTargetVariablesMissingFile(t *testing.T) {
+	sess := &session{varsFiles: []string{filepath.Join(t.TempDir(), "missing.yaml")}}
+	if _, err := targetVariables(sess, config.Target{}); err == nil {
+		t.Error("targetVariables() with a missing vars file: want error")
+	}
+}
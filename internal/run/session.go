@@ -0,0 +1,67 @@
+package run
+
+import (
+	"sync"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/source"
+)
+
+// session carries per-invocation state shared by every target processed in one run.
+type session struct {
+	settings config.Settings
+	log      *logger
+	fetched  flightGroup[source.Result] // source@ref -> fetched tree
+
+	varsFiles []string          // global varsFiles
+	links     map[string]string // target -> symlink path, for every target of the config
+
+	// overrides replace target variables of the same name (--set and friends)
+	overrides map[string]config.VarValue
+}
+
+func newSession(cfg *config.DuckConf) *session {
+	return &session{settings: cfg.Settings, log: newLogger(cfg.Settings.LogLevel), varsFiles: cfg.VarsFiles, links: targetLinks(cfg)}
+}
+
+// targetLinks returns the symlink path of every target of cfg.
+func targetLinks(cfg *config.DuckConf) map[string]string {
+	links := map[string]string{}
+	targets, _ := collectTargets(cfg, "")
+	for name, t := range targets {
+		links[name] = targetLinkPath(name, t)
+	}
+	return links
+}
+
+// flightGroup runs fn at most once per key for the lifetime of the group, sharing
+// the result with concurrent and later callers (deduplicates git work across targets).
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+func (g *flightGroup[T]) do(key string, fn func() (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall[T]{}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	c := &flightCall[T]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn()
+	close(c.done)
+	return c.val, c.err
+}
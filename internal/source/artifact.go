@@ -0,0 +1,76 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// artifactDir is where content identified by digest ("sha256:<hex>") is extracted.
+// Trees are content-addressed, so identical downloads share one directory.
+func artifactDir(cacheDir, digest string) string {
+	return filepath.Join(cacheDir, "artifacts", strings.Replace(digest, ":", "-", 1))
+}
+
+func dirExists(dir string) bool {
+	fi, err := os.Stat(dir)
+	return err == nil && fi.IsDir()
+}
+
+// materialize fills dir through fill, working in a temporary sibling directory
+// that is renamed into place so readers never observe a partial tree.
+func materialize(dir string, fill func(tmp string) error) error {
+	if dirExists(dir) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := fill(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil && !dirExists(dir) {
+		return err
+	}
+	return nil
+}
+
+// download streams r into a temporary file under cacheDir and returns its path
+// together with the content digest. The caller removes the file.
+func download(cacheDir string, r io.Reader) (string, string, error) {
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", "", err
+	}
+	f, err := os.CreateTemp(cacheDir, ".download-")
+	if err != nil {
+		return "", "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", "", err
+	}
+	return f.Name(), "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkDigest fails when got differs from a non-empty want.
+func checkDigest(what, want, got string) error {
+	if want != "" && !strings.EqualFold(want, got) {
+		return fmt.Errorf("%s: expected %s, got %s", what, want, got)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/filelock"
+	"github.com/CyberDuck79/duckfile/internal/git"
+)
+
+// gitSource fetches templates from a Git repository through the shared mirror store.
+type gitSource struct {
+	tpl  config.Template
+	opts Options
+}
+
+func (s *gitSource) store() *git.Store {
+	return &git.Store{Dir: s.opts.CacheDir, Warnf: s.opts.Log.Warnf}
+}
+
+func (s *gitSource) refs() refCache { return refCache{path: s.opts.RefsFile, maxAge: s.opts.RefMaxAge} }
+
+func (s *gitSource) cloneOptions() git.CloneOptions {
+	return git.CloneOptions{Shallow: s.tpl.IsShallow(), Submodules: s.tpl.Submodules}
+}
+
+// Fetch pins the ref to a commit (or uses req.Pin) and extracts that commit. A
+// semver constraint ref is first resolved to the highest matching tag.
+func (s *gitSource) Fetch(req Request) (Result, error) {
+	commit := req.Pin
+	if commit == "" && git.IsVersionConstraint(req.Ref) {
+		tag, commit, err := s.resolveConstraint(req.Ref, req.Refresh)
+		if err != nil {
+			return Result{}, err
+		}
+		res, err := s.checkout(req.Ref, tag, commit, false)
+		res.Version = tag
+		return res, err
+	}
+	if commit == "" {
+		var err error
+		if commit, err = s.resolve(req.Ref, req.Refresh); err != nil {
+			// Full clones may use history-dependent revisions (main~1, v1.2^) unknown to ls-remote
+			if s.tpl.IsShallow() {
+				return Result{}, err
+			}
+			if commit, err = s.resolveFromHistory(req.Ref); err != nil {
+				return Result{}, err
+			}
+		}
+	}
+	return s.checkout(req.Ref, "", commit, req.Pin != "")
+}
+
+// resolve returns the commit SHA for ref, reusing a previous answer younger than
+// RefMaxAge unless refresh is set.
+func (s *gitSource) resolve(ref string, refresh bool) (string, error) {
+	repo := s.tpl.Repo
+	if git.IsCommitSHA(ref) {
+		return git.ResolveRef(repo, ref)
+	}
+	id := repo + "@" + refOrHead(ref)
+	if sha, ok := s.refs().lookup(id); ok && !refresh {
+		s.opts.Log.Debugf("using cached resolution %s -> %s", id, sha)
+		return sha, nil
+	}
+	s.opts.Log.Debugf("resolving %s", id)
+	sha, err := git.ResolveRef(repo, ref)
+	if err != nil {
+		return "", err
+	}
+	return sha, s.refs().record(id, sha)
+}
+
+// resolveConstraint returns the highest tag satisfying constraint and its commit,
+// reusing a previous answer younger than RefMaxAge unless refresh is set.
+func (s *gitSource) resolveConstraint(constraint string, refresh bool) (string, string, error) {
+	id := s.tpl.Repo + "@" + constraint
+	if r, ok := s.refs().lookupRef(id); ok && r.Tag != "" && !refresh {
+		s.opts.Log.Debugf("using cached resolution %s -> %s (%s)", id, r.Tag, r.SHA)
+		return r.Tag, r.SHA, nil
+	}
+	s.opts.Log.Debugf("resolving %s", id)
+	tag, sha, err := git.ResolveConstraint(s.tpl.Repo, constraint)
+	if err != nil {
+		return "", "", err
+	}
+	return tag, sha, s.refs().recordRef(id, resolvedRef{SHA: sha, Tag: tag})
+}
+
+// checkout makes sure the store holds commit extracted on disk, fetching only when
+// the mirror does not have it yet. If the ref moved between resolution and fetch, the
+// fetched commit wins and is recorded under ref. A version constraint ref fetches the
+// tag it resolved to, which is recorded alongside. A pinned commit is fetched directly.
+func (s *gitSource) checkout(ref, tag, commit string, pinned bool) (Result, error) {
+	store, repo, opts := s.store(), s.tpl.Repo, s.cloneOptions()
+	if store.HasTree(repo, commit, opts.Submodules) {
+		return Result{Dir: store.TreeDir(repo, commit, opts.Submodules), Revision: commit}, nil
+	}
+	lk, err := filelock.Acquire(store.LockPath(repo))
+	if err != nil {
+		return Result{}, err
+	}
+	defer lk.Unlock()
+	// Another process may have extracted it while we waited for the lock
+	if store.HasTree(repo, commit, opts.Submodules) {
+		return Result{Dir: store.TreeDir(repo, commit, opts.Submodules), Revision: commit}, nil
+	}
+	if !store.HasCommit(repo, commit) || (!opts.Shallow && store.IsShallow(repo)) {
+		want := ref
+		if tag != "" {
+			want = tag
+		}
+		if pinned {
+			want = commit
+		}
+		s.opts.Log.Infof("fetching %s@%s", repo, refOrHead(want))
+		fetched, err := store.Fetch(repo, want, opts)
+		if err != nil {
+			return Result{}, err
+		}
+		if fetched != commit {
+			if pinned {
+				return Result{}, fmt.Errorf("fetched %s but duck.lock pins %s", fetched, commit)
+			}
+			s.opts.Log.Debugf("%s@%s moved to %s", repo, refOrHead(want), fetched)
+			if err := s.refs().recordRef(repo+"@"+refOrHead(ref), resolvedRef{SHA: fetched, Tag: tag}); err != nil {
+				return Result{}, err
+			}
+			commit = fetched
+		}
+	}
+	dir, err := store.Extract(repo, commit, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Dir: dir, Revision: commit}, nil
+}
+
+// resolveFromHistory fetches the full repository and resolves ref locally.
+func (s *gitSource) resolveFromHistory(ref string) (string, error) {
+	store, repo := s.store(), s.tpl.Repo
+	lk, err := filelock.Acquire(store.LockPath(repo))
+	if err != nil {
+		return "", err
+	}
+	defer lk.Unlock()
+	s.opts.Log.Infof("fetching full history of %s to resolve %s", repo, ref)
+	if err := store.FetchAll(repo); err != nil {
+		return "", err
+	}
+	return store.RevParse(repo, ref)
+}
+
+func refOrHead(ref string) string {
+	if strings.TrimSpace(ref) == "" {
+		return "HEAD"
+	}
+	return ref
+}
@@ -0,0 +1,73 @@
+package source
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func commitFile(t *testing.T, repo, content string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo, "a.tpl"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "--quiet", "-m", content)
+	return gitRun(t, repo, "rev-parse", "HEAD")
+}
+
+func TestGitFetchConstraintRecordsMovedTagUnderConstraint(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "--quiet")
+	commitFile(t, repo, "v1.0")
+	gitRun(t, repo, "tag", "v1.0.0")
+	commitFile(t, repo, "v1.1")
+	gitRun(t, repo, "tag", "v1.1.0")
+
+	opts := testOptions(t)
+	opts.RefMaxAge = time.Hour
+	s := &gitSource{tpl: config.Template{Repo: repo, Ref: "^1.0", Type: "git"}, opts: opts}
+	if _, _, err := s.resolveConstraint("^1.0", false); err != nil {
+		t.Fatal(err)
+	}
+	// v1.1.0 moves before the mirror first fetches it
+	moved := commitFile(t, repo, "v1.1 again")
+	gitRun(t, repo, "tag", "-f", "v1.1.0")
+
+	res, err := s.Fetch(Request{Ref: "^1.0"})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if res.Revision != moved || res.Version != "v1.1.0" {
+		t.Errorf("Fetch() = %s (%s), want %s (v1.1.0)", res.Revision, res.Version, moved)
+	}
+	refs := s.refs().load()
+	if r := refs[repo+"@^1.0"]; r.SHA != moved || r.Tag != "v1.1.0" {
+		t.Errorf("%s@^1.0 recorded as %s (%s), want %s (v1.1.0)", repo, r.SHA, r.Tag, moved)
+	}
+	if _, ok := refs[repo+"@v1.1.0"]; ok {
+		t.Errorf("resolution recorded under the tag instead of the constraint")
+	}
+	if got := ResolvedVersion(s.tpl, opts); got != "v1.1.0" {
+		t.Errorf("ResolvedVersion() = %q, want v1.1.0", got)
+	}
+}
@@ -0,0 +1,106 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CyberDuck79/duckfile/internal/archive"
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+// httpSource downloads a .tar, .tar.gz/.tgz or .zip archive over HTTP(S).
+// The revision is the SHA-256 digest of the downloaded archive.
+type httpSource struct {
+	tpl  config.Template
+	opts Options
+}
+
+func isArchiveURL(u string) bool {
+	if i := strings.IndexAny(u, "?#"); i != -1 {
+		u = u[:i]
+	}
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(u), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch downloads and extracts the archive unless the wanted digest is already on disk.
+// URLs are remembered like Git refs, so a mutable URL is re-downloaded at most once
+// per refresh interval.
+func (s *httpSource) Fetch(req Request) (Result, error) {
+	if req.Ref != "" {
+		return Result{}, fmt.Errorf("template.ref is not supported for http sources; put the version in the URL")
+	}
+	url := s.tpl.Repo
+	refs := refCache{path: s.opts.RefsFile, maxAge: s.opts.RefMaxAge}
+	want := req.Pin
+	if want == "" {
+		if sum := strings.TrimSpace(s.tpl.ArchiveChecksum); sum != "" {
+			want = "sha256:" + strings.ToLower(sum)
+		} else if rev, ok := refs.lookup(url); ok && !req.Refresh {
+			s.opts.Log.Debugf("using cached download %s -> %s", url, rev)
+			want = rev
+		}
+	}
+	if want != "" && dirExists(artifactDir(s.opts.CacheDir, want)) {
+		return Result{Dir: artifactDir(s.opts.CacheDir, want), Revision: want}, nil
+	}
+
+	s.opts.Log.Infof("downloading %s", url)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	file, digest, err := download(filepath.Join(s.opts.CacheDir, "artifacts"), resp.Body)
+	if err != nil {
+		return Result{}, fmt.Errorf("download %s: %w", url, err)
+	}
+	defer os.Remove(file)
+	if sum := strings.TrimSpace(s.tpl.ArchiveChecksum); sum != "" {
+		if err := checkDigest("archive checksum mismatch for "+url, "sha256:"+sum, digest); err != nil {
+			return Result{}, err
+		}
+	}
+	if err := checkDigest("archive digest mismatch for "+url+" (duck.lock)", req.Pin, digest); err != nil {
+		return Result{}, err
+	}
+	dir := artifactDir(s.opts.CacheDir, digest)
+	if err := materialize(dir, func(tmp string) error { return extractArchive(file, tmp) }); err != nil {
+		return Result{}, fmt.Errorf("extract %s: %w", url, err)
+	}
+	if err := refs.record(url, digest); err != nil {
+		return Result{}, err
+	}
+	return Result{Dir: dir, Revision: digest}, nil
+}
+
+// extractArchive unpacks the archive at path into dir, detecting the format from its content.
+func extractArchive(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return archive.ExtractZip(path, dir)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return archive.ExtractTarGz(br, dir)
+	default:
+		return archive.ExtractTar(br, dir)
+	}
+}
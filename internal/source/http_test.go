@@ -0,0 +1,91 @@
+package source
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+func TestHTTPFetch(t *testing.T) {
+	tgz := tarball(t, true, map[string]string{"Makefile.tpl": "all:\n"})
+	sum := sha256Hex(tgz)
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/tpl.tar.gz" {
+			http.NotFound(w, req)
+			return
+		}
+		downloads++
+		w.Write(tgz)
+	}))
+	defer srv.Close()
+	url := srv.URL + "/tpl.tar.gz"
+
+	tests := []struct {
+		name     string
+		url      string
+		checksum string
+		pin      string
+		wantErr  string
+	}{
+		{name: "no checksum", url: url},
+		{name: "matching checksum", url: url, checksum: strings.ToUpper(sum)},
+		{name: "matching pin", url: url, pin: "sha256:" + sum},
+		{name: "checksum mismatch", url: url, checksum: strings.Repeat("0", 64), wantErr: "archive checksum mismatch"},
+		{name: "pin mismatch", url: url, pin: "sha256:" + strings.Repeat("0", 64), wantErr: "archive digest mismatch"},
+		{name: "not found", url: srv.URL + "/missing.tar.gz", wantErr: "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &httpSource{tpl: config.Template{Repo: tt.url, ArchiveChecksum: tt.checksum}, opts: testOptions(t)}
+			res, err := s.Fetch(Request{Pin: tt.pin})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Fetch() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if res.Revision != "sha256:"+sum {
+				t.Errorf("Revision = %s, want sha256:%s", res.Revision, sum)
+			}
+			if got := readTree(t, res.Dir, "Makefile.tpl")["Makefile.tpl"]; got != "all:\n" {
+				t.Errorf("Makefile.tpl = %q", got)
+			}
+		})
+	}
+
+	// A remembered URL is not downloaded again
+	s := &httpSource{tpl: config.Template{Repo: url}, opts: testOptions(t)}
+	s.opts.RefMaxAge = time.Hour
+	before := downloads
+	for i := 0; i < 2; i++ {
+		if _, err := s.Fetch(Request{}); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+	}
+	if n := downloads - before; n != 1 {
+		t.Errorf("downloaded %d times, want 1", n)
+	}
+}
+
+func TestIsArchiveURL(t *testing.T) {
+	for u, want := range map[string]bool{
+		"https://x/t.tar.gz":       true,
+		"https://x/t.TGZ":          true,
+		"https://x/t.zip?token=a":  true,
+		"https://x/t.tar#frag":     true,
+		"https://github.com/a/b":   false,
+		"https://x/t.tar.gz/other": false,
+	} {
+		if got := isArchiveURL(u); got != want {
+			t.Errorf("isArchiveURL(%q) = %v, want %v", u, got, want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package source
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CyberDuck79/duckfile/internal/archive"
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+// ociSource pulls an OCI artifact (e.g. pushed with oras) from a registry. The
+// repo is oci://<registry>/<name>, the ref a tag or sha256 digest (default latest)
+// and the revision the manifest digest.
+type ociSource struct {
+	tpl   config.Template
+	opts  Options
+	token string // bearer token obtained from the registry's auth challenge
+}
+
+const (
+	ociTitleAnnotation   = "org.opencontainers.image.title"
+	orasUnpackAnnotation = "io.deis.oras.content.unpack"
+)
+
+var ociManifestTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// Fetch resolves the tag to a manifest digest and extracts the artifact's layers.
+func (s *ociSource) Fetch(req Request) (Result, error) {
+	host, name, err := parseOCIRepo(s.tpl.Repo)
+	if err != nil {
+		return Result{}, err
+	}
+	ref := req.Ref
+	if ref == "" {
+		ref = "latest"
+	}
+	refs := refCache{path: s.opts.RefsFile, maxAge: s.opts.RefMaxAge}
+	id := s.tpl.Repo + "@" + ref
+	digest := req.Pin
+	if digest == "" && strings.HasPrefix(ref, "sha256:") {
+		digest = ref
+	} else if rev, ok := refs.lookup(id); digest == "" && ok && !req.Refresh {
+		s.opts.Log.Debugf("using cached resolution %s -> %s", id, rev)
+		digest = rev
+	}
+	if digest != "" && dirExists(artifactDir(s.opts.CacheDir, digest)) {
+		return Result{Dir: artifactDir(s.opts.CacheDir, digest), Revision: digest}, nil
+	}
+
+	want := digest
+	if want == "" {
+		want = ref
+	}
+	s.opts.Log.Infof("pulling %s@%s", s.tpl.Repo, want)
+	body, err := s.get(host, name, "manifests/"+want, strings.Join(ociManifestTypes, ", "))
+	if err != nil {
+		return Result{}, err
+	}
+	got := "sha256:" + sha256Hex(body)
+	if err := checkDigest("manifest digest mismatch for "+s.tpl.Repo, digest, got); err != nil {
+		return Result{}, err
+	}
+	var m ociManifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return Result{}, fmt.Errorf("parse manifest of %s@%s: %w", s.tpl.Repo, want, err)
+	}
+	if len(m.Layers) == 0 {
+		return Result{}, fmt.Errorf("%s@%s has no layers", s.tpl.Repo, want)
+	}
+	dir := artifactDir(s.opts.CacheDir, got)
+	err = materialize(dir, func(tmp string) error {
+		for _, l := range m.Layers {
+			if err := s.pullLayer(host, name, l, tmp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	if !strings.HasPrefix(ref, "sha256:") && req.Pin == "" {
+		if err := refs.record(id, got); err != nil {
+			return Result{}, err
+		}
+	}
+	return Result{Dir: dir, Revision: got}, nil
+}
+
+// pullLayer downloads one layer, verifies its digest and unpacks it into dir.
+// Titled layers (oras files) are written under their title, or extracted there
+// when marked for unpacking; untitled tar layers are extracted at the root.
+func (s *ociSource) pullLayer(host, name string, l ociDescriptor, dir string) error {
+	blob, err := s.get(host, name, "blobs/"+l.Digest, "")
+	if err != nil {
+		return err
+	}
+	if err := checkDigest("layer digest mismatch in "+s.tpl.Repo, l.Digest, "sha256:"+sha256Hex(blob)); err != nil {
+		return err
+	}
+	gz := strings.HasSuffix(l.MediaType, "+gzip") || strings.HasSuffix(l.MediaType, ".gzip")
+	title := l.Annotations[ociTitleAnnotation]
+	if title == "" {
+		if !strings.Contains(l.MediaType, "tar") {
+			return fmt.Errorf("layer %s of %s has no title and is not a tar archive (%s)", l.Digest, s.tpl.Repo, l.MediaType)
+		}
+		return extractLayer(blob, gz, dir)
+	}
+	p := filepath.Join(dir, filepath.Clean(filepath.FromSlash("/"+title)))
+	if l.Annotations[orasUnpackAnnotation] == "true" {
+		return extractLayer(blob, true, p)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, blob, 0o644)
+}
+
+func extractLayer(blob []byte, gz bool, dir string) error {
+	if gz {
+		return archive.ExtractTarGz(bytes.NewReader(blob), dir)
+	}
+	return archive.ExtractTar(bytes.NewReader(blob), dir)
+}
+
+// get performs a registry API request, answering a bearer-token challenge anonymously.
+func (s *ociSource) get(host, name, path, accept string) ([]byte, error) {
+	u := registryScheme(host) + "://" + host + "/v2/" + name + "/" + path
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if s.token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.token)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			if s.token, err = fetchToken(resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, fmt.Errorf("authenticate to %s: %w", host, err)
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+		}
+		return body, nil
+	}
+}
+
+// fetchToken requests an anonymous token from the realm named in a
+// `Bearer realm="...",service="...",scope="..."` challenge.
+func fetchToken(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+	q := url.Values{}
+	var realm string
+	for _, kv := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		v = strings.Trim(v, `"`)
+		if k == "realm" {
+			realm = v
+		} else if k != "" {
+			q.Set(k, v)
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("auth challenge without realm")
+	}
+	resp, err := httpClient.Get(realm + "?" + q.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request: %s", resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	if tok.Token != "" {
+		return tok.Token, nil
+	}
+	return tok.AccessToken, nil
+}
+
+// parseOCIRepo splits oci://<registry>/<name> into registry host and repository name.
+func parseOCIRepo(repo string) (string, string, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(repo), "oci://")
+	host, name, ok := strings.Cut(rest, "/")
+	if !ok || host == "" || name == "" {
+		return "", "", fmt.Errorf("invalid OCI reference %q (expected oci://<registry>/<name>)", repo)
+	}
+	return host, strings.TrimSuffix(name, "/"), nil
+}
+
+// registryScheme uses plain HTTP for registries on the local machine, HTTPS otherwise.
+func registryScheme(host string) string {
+	h := host
+	if i := strings.LastIndex(h, ":"); i != -1 && !strings.HasSuffix(h, "]") {
+		h = h[:i]
+	}
+	switch strings.Trim(h, "[]") {
+	case "localhost", "127.0.0.1", "::1":
+		return "http"
+	}
+	return "https"
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
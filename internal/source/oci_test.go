@@ -0,0 +1,274 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...any) {}
+func (nopLogger) Infof(string, ...any)  {}
+func (nopLogger) Warnf(string, ...any)  {}
+
+func testOptions(t *testing.T) Options {
+	dir := t.TempDir()
+	return Options{CacheDir: dir, RefsFile: filepath.Join(dir, "refs.json"), Log: nopLogger{}}
+}
+
+// tarball returns a tar stream of files (name -> content), gzipped when gz is set.
+func tarball(t *testing.T, gz bool, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(zw)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(body))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// registry is a fake OCI registry serving one repository, "tpl", behind an
+// anonymous bearer token.
+type registry struct {
+	*httptest.Server
+	manifests map[string][]byte // tag or digest -> manifest
+	blobs     map[string][]byte // digest -> blob
+	tokens    int               // token requests served
+}
+
+func newRegistry(t *testing.T) *registry {
+	r := &registry{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("scope") != "repository:tpl:pull" || req.URL.Query().Get("service") != "test" {
+			http.Error(w, "bad scope", http.StatusBadRequest)
+			return
+		}
+		r.tokens++
+		json.NewEncoder(w).Encode(map[string]string{"token": "secret"})
+	})
+	mux.HandleFunc("/v2/tpl/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.URL+`/token",service="test",scope="repository:tpl:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		kind, ref, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/v2/tpl/"), "/")
+		var body []byte
+		var ok bool
+		switch kind {
+		case "manifests":
+			body, ok = r.manifests[ref]
+		case "blobs":
+			body, ok = r.blobs[ref]
+		}
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(body)
+	})
+	r.Server = httptest.NewServer(mux)
+	t.Cleanup(r.Close)
+	return r
+}
+
+// push publishes a manifest of layers under tag and returns its digest.
+func (r *registry) push(t *testing.T, tag string, layers ...ociDescriptor) string {
+	t.Helper()
+	m, err := json.Marshal(ociManifest{MediaType: ociManifestTypes[0], Layers: layers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := "sha256:" + sha256Hex(m)
+	r.manifests[tag] = m
+	r.manifests[digest] = m
+	return digest
+}
+
+// layer adds blob and returns its descriptor.
+func (r *registry) layer(blob []byte, mediaType string, annotations map[string]string) ociDescriptor {
+	d := ociDescriptor{MediaType: mediaType, Digest: "sha256:" + sha256Hex(blob), Size: int64(len(blob)), Annotations: annotations}
+	r.blobs[d.Digest] = blob
+	return d
+}
+
+func (r *registry) source(t *testing.T) *ociSource {
+	return &ociSource{tpl: config.Template{Repo: "oci://" + strings.TrimPrefix(r.URL, "http://") + "/tpl"}, opts: testOptions(t)}
+}
+
+func readTree(t *testing.T, dir string, names ...string) map[string]string {
+	t.Helper()
+	got := map[string]string{}
+	for _, n := range names {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(n)))
+		if err != nil {
+			t.Errorf("%s: %v", n, err)
+			continue
+		}
+		got[n] = string(b)
+	}
+	return got
+}
+
+func TestOCIFetchLayers(t *testing.T) {
+	r := newRegistry(t)
+	digest := r.push(t, "v1",
+		r.layer([]byte("all:\n"), "application/vnd.oci.image.layer.v1.tar", map[string]string{ociTitleAnnotation: "Makefile.tpl"}),
+		r.layer(tarball(t, true, map[string]string{"a.tpl": "a"}), "application/vnd.oci.image.layer.v1.tar+gzip",
+			map[string]string{ociTitleAnnotation: "dir", orasUnpackAnnotation: "true"}),
+		r.layer(tarball(t, false, map[string]string{"root/b.tpl": "b"}), "application/vnd.oci.image.layer.v1.tar", nil),
+	)
+
+	res, err := r.source(t).Fetch(Request{Ref: "v1"})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if res.Revision != digest {
+		t.Errorf("Revision = %s, want %s", res.Revision, digest)
+	}
+	want := map[string]string{"Makefile.tpl": "all:\n", "dir/a.tpl": "a", "root/b.tpl": "b"}
+	got := readTree(t, res.Dir, "Makefile.tpl", "dir/a.tpl", "root/b.tpl")
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if r.tokens != 1 {
+		t.Errorf("token requested %d times, want 1", r.tokens)
+	}
+}
+
+func TestOCIFetchPinnedUsesCache(t *testing.T) {
+	r := newRegistry(t)
+	digest := r.push(t, "v1", r.layer([]byte("x"), "text/plain", map[string]string{ociTitleAnnotation: "x.tpl"}))
+	s := r.source(t)
+	if _, err := s.Fetch(Request{Ref: "v1", Pin: digest}); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	r.Close() // a pinned artifact already on disk needs no registry
+	res, err := s.Fetch(Request{Ref: "v1", Pin: digest})
+	if err != nil {
+		t.Fatalf("Fetch() from cache error = %v", err)
+	}
+	if got := readTree(t, res.Dir, "x.tpl")["x.tpl"]; got != "x" {
+		t.Errorf("x.tpl = %q, want %q", got, "x")
+	}
+}
+
+func TestOCIFetchErrors(t *testing.T) {
+	titled := map[string]string{ociTitleAnnotation: "x.tpl"}
+	tests := []struct {
+		name    string
+		layer   func(r *registry) ociDescriptor
+		wantErr string
+	}{
+		{
+			name: "layer digest mismatch",
+			layer: func(r *registry) ociDescriptor {
+				d := r.layer([]byte("good"), "text/plain", titled)
+				r.blobs[d.Digest] = []byte("evil")
+				return d
+			},
+			wantErr: "layer digest mismatch",
+		},
+		{
+			name:    "untitled non-tar layer",
+			layer:   func(r *registry) ociDescriptor { return r.layer([]byte("x"), "text/plain", nil) },
+			wantErr: "has no title and is not a tar archive",
+		},
+		{
+			name: "missing blob",
+			layer: func(r *registry) ociDescriptor {
+				return ociDescriptor{MediaType: "text/plain", Digest: "sha256:" + sha256Hex([]byte("gone")), Annotations: titled}
+			},
+			wantErr: "404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRegistry(t)
+			m, _ := json.Marshal(ociManifest{Layers: []ociDescriptor{tt.layer(r)}})
+			r.manifests["v1"] = m
+			_, err := r.source(t).Fetch(Request{Ref: "v1"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Fetch() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOCIManifestDigestMismatch(t *testing.T) {
+	r := newRegistry(t)
+	digest := r.push(t, "v1", r.layer([]byte("x"), "text/plain", map[string]string{ociTitleAnnotation: "x.tpl"}))
+	r.manifests[digest] = []byte(`{"layers":[]}`)
+	_, err := r.source(t).Fetch(Request{Ref: "v1", Pin: digest})
+	if err == nil || !strings.Contains(err.Error(), "manifest digest mismatch") {
+		t.Errorf("Fetch() error = %v, want manifest digest mismatch", err)
+	}
+}
+
+func TestFetchToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"access_token":"tok"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		challenge string
+		want      string
+		wantErr   bool
+	}{
+		{challenge: `Bearer realm="` + srv.URL + `",service="s"`, want: "tok"},
+		{challenge: `Basic realm="x"`, wantErr: true},
+		{challenge: `Bearer service="s"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := fetchToken(tt.challenge)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("fetchToken(%q) = %q, %v; want %q, error %v", tt.challenge, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRegistryScheme(t *testing.T) {
+	for host, want := range map[string]string{
+		"localhost:5000":  "http",
+		"127.0.0.1:5000":  "http",
+		"[::1]:5000":      "http",
+		"ghcr.io":         "https",
+		"example.com:443": "https",
+	} {
+		if got := registryScheme(host); got != want {
+			t.Errorf("registryScheme(%q) = %s, want %s", host, got, want)
+		}
+	}
+}
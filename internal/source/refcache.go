@@ -0,0 +1,72 @@
+package source
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/CyberDuck79/duckfile/internal/filelock"
+	"github.com/CyberDuck79/duckfile/internal/fsutil"
+)
+
+// refCache remembers the revision each source@ref resolved to so that duck does
+// not query the remote on every invocation.
+type refCache struct {
+	path   string
+	maxAge time.Duration
+}
+
+type resolvedRef struct {
+	SHA        string    `json:"sha"`
+	Tag        string    `json:"tag,omitempty"` // tag chosen for a version constraint
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+func (c refCache) load() map[string]resolvedRef {
+	refs := map[string]resolvedRef{}
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		return refs
+	}
+	_ = json.Unmarshal(b, &refs) // a corrupt cache is simply rebuilt
+	return refs
+}
+
+// lookup returns a remembered revision younger than maxAge.
+func (c refCache) lookup(id string) (string, bool) {
+	r, ok := c.lookupRef(id)
+	return r.SHA, ok
+}
+
+func (c refCache) lookupRef(id string) (resolvedRef, bool) {
+	r, ok := c.load()[id]
+	if !ok || time.Since(r.ResolvedAt) >= c.maxAge {
+		return resolvedRef{}, false
+	}
+	return r, true
+}
+
+// record stores the revision id resolved to.
+func (c refCache) record(id, rev string) error {
+	return c.recordRef(id, resolvedRef{SHA: rev})
+}
+
+func (c refCache) recordRef(id string, r resolvedRef) error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	lk, err := filelock.Acquire(c.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+	refs := c.load()
+	r.ResolvedAt = time.Now()
+	refs[id] = r
+	b, err := json.MarshalIndent(refs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(c.path, b, 0o644)
+}
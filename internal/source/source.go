@@ -0,0 +1,130 @@
+// Package source fetches template content from the places duck supports: Git
+// repositories, HTTP(S) archives, OCI registries and the local filesystem.
+package source
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CyberDuck79/duckfile/internal/config"
+	"github.com/CyberDuck79/duckfile/internal/git"
+)
+
+// Source kinds, as written in template.type.
+const (
+	KindGit   = "git"
+	KindHTTP  = "http"
+	KindOCI   = "oci"
+	KindLocal = "local"
+)
+
+// Source materializes a template source at a requested version.
+type Source interface {
+	// Fetch makes the content available on disk and returns its directory together
+	// with an immutable revision (commit SHA or content digest).
+	Fetch(req Request) (Result, error)
+}
+
+// Request describes which version to fetch.
+type Request struct {
+	// Ref is the version written in duck.yaml (branch/tag/commit, semver
+	// constraint, OCI tag...).
+	Ref string
+	// Pin is an exact revision from duck.lock; when set, Ref is not resolved.
+	Pin string
+	// Refresh ignores remembered ref resolutions and cached downloads.
+	Refresh bool
+}
+
+// Result is fetched content.
+type Result struct {
+	// Dir holds the fetched tree; template.path is relative to it.
+	Dir string
+	// Revision identifies the content immutably. Empty for local sources.
+	Revision string
+	// Version is the tag a version constraint ref resolved to; empty otherwise.
+	Version string
+}
+
+// httpClient serves archive downloads and registry requests. Its timeouts bound
+// the whole exchange, body included, so a stalled server cannot hold the target and
+// store locks forever.
+var httpClient = newHTTPClient(10*time.Minute, time.Minute)
+
+func newHTTPClient(timeout, headerTimeout time.Duration) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.ResponseHeaderTimeout = headerTimeout
+	return &http.Client{Timeout: timeout, Transport: tr}
+}
+
+// Logger receives progress messages.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+}
+
+// Options configures the sources built by New.
+type Options struct {
+	// CacheDir is the root for repository mirrors, downloads and extracted trees.
+	CacheDir string
+	// RefsFile remembers ref resolutions between runs.
+	RefsFile string
+	// RefMaxAge is how long a remembered resolution is trusted.
+	RefMaxAge time.Duration
+	Log       Logger
+}
+
+// Kind returns the source kind of tpl: template.type when set, otherwise inferred
+// from the repo URL (oci:// => oci, http(s) archive => http, file:// or plain path
+// => local, anything else => git).
+func Kind(tpl config.Template) string {
+	if tpl.Type != "" {
+		return tpl.Type
+	}
+	if _, ok := tpl.LocalDir(); ok {
+		return KindLocal
+	}
+	repo := strings.TrimSpace(tpl.Repo)
+	switch {
+	case strings.HasPrefix(repo, "oci://"):
+		return KindOCI
+	case (strings.HasPrefix(repo, "https://") || strings.HasPrefix(repo, "http://")) && isArchiveURL(repo):
+		return KindHTTP
+	default:
+		return KindGit
+	}
+}
+
+// New returns the Source for tpl.
+func New(tpl config.Template, opts Options) (Source, error) {
+	switch kind := Kind(tpl); kind {
+	case KindGit:
+		return &gitSource{tpl: tpl, opts: opts}, nil
+	case KindHTTP:
+		return &httpSource{tpl: tpl, opts: opts}, nil
+	case KindOCI:
+		return &ociSource{tpl: tpl, opts: opts}, nil
+	case KindLocal:
+		dir, _ := tpl.LocalDir()
+		return localSource(dir), nil
+	default:
+		return nil, fmt.Errorf("unknown template source type %q", kind)
+	}
+}
+
+// ResolvedVersion returns the tag tpl's version constraint last resolved to, as
+// remembered in opts.RefsFile regardless of its age, or "" if unknown.
+func ResolvedVersion(tpl config.Template, opts Options) string {
+	if Kind(tpl) != KindGit || !git.IsVersionConstraint(tpl.Ref) {
+		return ""
+	}
+	return refCache{path: opts.RefsFile}.load()[tpl.Repo+"@"+tpl.Ref].Tag
+}
+
+// localSource reads templates straight from a directory.
+type localSource string
+
+func (s localSource) Fetch(Request) (Result, error) { return Result{Dir: string(s)}, nil }